@@ -0,0 +1,117 @@
+// Command reencrypt rewrites the encrypted secrets stored in the database with the active master key.
+// Run it after adding a new key version, before removing the old key from the configuration.
+// Plaintext values stored before encryption was enabled are encrypted as well.
+//
+// It reads the same environment (or .env file) as the server: the database settings and
+// SECRETS_KEYS, SECRETS_KEYS_FILE, SECRETS_KEY and SECRETS_ACTIVE_KEY_VERSION.
+//
+// Usage:
+//
+//	go run ./cmd/reencrypt [-dry-run]
+package main
+
+import (
+	"flag"
+	"log"
+	"reflect"
+	"webcrawler-backend/internal/database"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/secrets"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+)
+
+// target is a column holding encrypted values
+type target struct {
+	model  interface{}
+	table  string
+	column string
+}
+
+// targets lists every field declared with serializer:encrypted
+var targets = []target{
+	{&models.User{}, "users", "mfa_secret"},
+	{&models.SigningKey{}, "signing_keys", "private_key_pem"},
+	{&models.CrawlResult{}, "crawl_results", "credentials"},
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only count the values that would be rewritten")
+	flag.Parse()
+
+	godotenv.Load()
+
+	keyring, err := secrets.KeyringFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid secrets encryption keys: %v", err)
+	}
+	if keyring == nil {
+		log.Fatal("No secrets encryption key configured (set SECRETS_KEYS)")
+	}
+	secrets.SetDefault(keyring)
+
+	db, err := database.InitDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	total := 0
+	for _, t := range targets {
+		count, err := reencrypt(db, keyring, t, *dryRun)
+		if err != nil {
+			log.Fatalf("Failed to re-encrypt %s.%s: %v", t.table, t.column, err)
+		}
+		log.Printf("%s.%s: %d values to re-encrypt", t.table, t.column, count)
+		total += count
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d values are not encrypted with key %d", total, keyring.ActiveVersion())
+		return
+	}
+	log.Printf("Re-encrypted %d values with key %d", total, keyring.ActiveVersion())
+}
+
+// reencrypt rewrites the values of a column not sealed with the active key and returns how many there were
+func reencrypt(db *gorm.DB, keyring *secrets.Keyring, t target, dryRun bool) (int, error) {
+	rows, err := db.Table(t.table).Select("id", t.column).
+		Where(t.column + " IS NOT NULL AND " + t.column + " <> ''").Rows()
+	if err != nil {
+		return 0, err
+	}
+
+	var stale []uint
+	for rows.Next() {
+		var id uint
+		var value []byte
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !keyring.IsCurrent(value) {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return len(stale), nil
+	}
+
+	// Loading decrypts with whichever key sealed the value; saving seals with the active key
+	modelType := reflect.TypeOf(t.model).Elem()
+	for _, id := range stale {
+		record := reflect.New(modelType).Interface()
+		if err := db.Unscoped().First(record, id).Error; err != nil {
+			return 0, err
+		}
+		if err := db.Unscoped().Session(&gorm.Session{SkipHooks: true}).
+			Model(record).Select(t.column).Updates(record).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), nil
+}
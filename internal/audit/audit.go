@@ -0,0 +1,153 @@
+// Package audit records security and crawl-management actions in the append-only audit_logs table.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"webcrawler-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Actions written to the audit log
+const (
+	ActionLogin                = "auth.login"
+	ActionLoginFailed          = "auth.login_failed"
+	ActionLogout               = "auth.logout"
+	ActionRegister             = "auth.register"
+	ActionAccountLinked        = "auth.account_linked"
+	ActionEmailVerified        = "auth.email_verified"
+	ActionPasswordChanged      = "auth.password_changed"
+	ActionPasswordReset        = "auth.password_reset"
+	ActionMFAEnabled           = "auth.mfa_enabled"
+	ActionMFADisabled          = "auth.mfa_disabled"
+	ActionRecoveryCodesRotated = "auth.recovery_codes_regenerated"
+	ActionProfileUpdated       = "user.profile_updated"
+	ActionUserUnlocked         = "admin.user_unlocked"
+	ActionImpersonationStarted = "admin.impersonation_started"
+	ActionUserRoleChanged      = "admin.user_role_changed"
+	ActionQuotaUpdated         = "admin.quota_updated"
+	ActionQuotaDeleted         = "admin.quota_deleted"
+	ActionAllowlistAdded       = "admin.allowlist_add"
+	ActionAllowlistRemoved     = "admin.allowlist_remove"
+	ActionCrawlCreated         = "crawl.create"
+	ActionCrawlProcessed       = "crawl.process"
+	ActionCrawlsProcessed      = "crawl.process_all"
+	ActionCrawlStopped         = "crawl.stop"
+	ActionCrawlPaused          = "crawl.pause"
+	ActionCrawlResumed         = "crawl.resume"
+	ActionCrawlPrioritized     = "crawl.prioritize"
+	ActionCrawlDeleted         = "crawl.delete"
+	ActionPresetCreated        = "preset.create"
+	ActionPresetUpdated        = "preset.update"
+	ActionPresetDeleted        = "preset.delete"
+	ActionOrganizationCreated  = "org.create"
+	ActionOrganizationUpdated  = "org.update"
+	ActionOrganizationDeleted  = "org.delete"
+	ActionMemberAdded          = "org.member_add"
+	ActionMemberRoleChanged    = "org.member_role_change"
+	ActionMemberRemoved        = "org.member_remove"
+)
+
+// Target types
+const (
+	TargetUser         = "user"
+	TargetCrawl        = "crawl"
+	TargetOrganization = "organization"
+	TargetMembership   = "membership"
+	TargetQuota        = "quota"
+	TargetPreset       = "preset"
+	TargetAllowlist    = "network_allowlist"
+)
+
+// Entry describes one action. Actor, IP address and user agent are taken from the request.
+type Entry struct {
+	Action     string
+	TargetType string
+	TargetID   interface{}
+	Before     interface{}
+	After      interface{}
+
+	// Actor overrides the authenticated user, e.g. for logins where the context has no user yet
+	Actor *models.User
+}
+
+// Logger writes audit log entries
+type Logger struct {
+	db *gorm.DB
+}
+
+// NewLogger creates a new audit logger
+func NewLogger(db *gorm.DB) *Logger {
+	return &Logger{db: db}
+}
+
+// Record appends an entry. Failures are logged and never fail the request.
+func (l *Logger) Record(c *gin.Context, entry Entry) {
+	if err := l.db.Create(newRecord(c, entry)).Error; err != nil {
+		log.Printf("[WARN] Failed to write audit log entry %s: %v", entry.Action, err)
+	}
+}
+
+// RecordTx appends an entry within a transaction, so the entry and the change it
+// describes are committed together, or not at all when it fails
+func (l *Logger) RecordTx(tx *gorm.DB, c *gin.Context, entry Entry) error {
+	if err := tx.Create(newRecord(c, entry)).Error; err != nil {
+		return fmt.Errorf("failed to write audit log entry %s: %v", entry.Action, err)
+	}
+	return nil
+}
+
+// newRecord builds the audit log row of an entry
+func newRecord(c *gin.Context, entry Entry) *models.AuditLog {
+	record := &models.AuditLog{
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  truncate(c.Request.UserAgent(), 500),
+		Before:     encode(entry.Before),
+		After:      encode(entry.After),
+	}
+	if entry.TargetID != nil {
+		record.TargetID = fmt.Sprint(entry.TargetID)
+	}
+
+	if entry.Actor != nil {
+		record.ActorID = &entry.Actor.ID
+		record.ActorEmail = entry.Actor.Email
+	} else if userID, ok := c.Get("user_id"); ok {
+		id := userID.(uint)
+		record.ActorID = &id
+		record.ActorEmail = c.GetString("user_email")
+	}
+
+	// Impersonated actions are attributed to the admin behind them as well
+	if impersonatorID, ok := c.Get("impersonator_id"); ok {
+		id := impersonatorID.(uint)
+		record.ImpersonatorID = &id
+		record.ImpersonatorEmail = c.GetString("impersonator_email")
+	}
+	return record
+}
+
+// encode marshals a before/after state; nil stays null
+func encode(v interface{}) models.JSON {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[WARN] Failed to encode audit log state: %v", err)
+		return nil
+	}
+	return data
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
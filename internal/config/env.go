@@ -0,0 +1,24 @@
+// Package config reads settings from environment variables.
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvInt reads an integer environment variable or returns a default value
+func EnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// EnvDuration reads a duration environment variable (e.g. "15m") or returns a default value
+func EnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PageAnalysis is what the crawler extracts from an HTML page
+type PageAnalysis struct {
+	Title         string
+	Doctype       Doctype
+	HeadingCounts map[string]int
+	Links         []*url.URL // Absolute http(s) links, in document order, without duplicates
+	Forms         []Form     // The forms of the page (at most maxPageForms), classified
+	HasLoginForm  bool       // A form has a password field
+}
+
+// Analyze parses an HTML document. Relative links are resolved against base
+// (or the document's <base href> when present).
+func Analyze(base *url.URL, r io.Reader) (*PageAnalysis, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(strings.NewReader(documentText(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := &PageAnalysis{
+		Doctype:       DetectDoctype(data),
+		HeadingCounts: make(map[string]int),
+	}
+	seen := make(map[string]bool)
+
+	var walk func(n *html.Node, form *formState)
+	walk = func(n *html.Node, form *formState) {
+		switch n.Type {
+		case html.ElementNode:
+			switch n.Data {
+			case "title":
+				if analysis.Title == "" && n.FirstChild != nil {
+					analysis.Title = strings.TrimSpace(n.FirstChild.Data)
+				}
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				analysis.HeadingCounts[n.Data]++
+			case "base":
+				if href := attr(n, "href"); href != "" {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case "a":
+				if u := resolveLink(base, attr(n, "href")); u != nil && !seen[u.String()] {
+					seen[u.String()] = true
+					analysis.Links = append(analysis.Links, u)
+				}
+			case "form":
+				form = newFormState(base, n)
+				defer func(f *formState) {
+					if len(analysis.Forms) < maxPageForms {
+						analysis.Forms = append(analysis.Forms, f.finish())
+					}
+					if f.password {
+						analysis.HasLoginForm = true
+					}
+				}(form)
+			case "input", "select", "textarea":
+				if form != nil {
+					form.addField(n)
+				}
+			case "button":
+				if form != nil && !strings.EqualFold(attr(n, "type"), "reset") {
+					text := textContent(n)
+					if text == "" {
+						text = attr(n, "aria-label")
+					}
+					form.addButton(text)
+				}
+			case "label":
+				if form != nil {
+					form.text = append(form.text, textContent(n))
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, form)
+		}
+	}
+	walk(doc, nil)
+	return analysis, nil
+}
+
+// resolveLink returns the absolute http(s) URL of an href, without fragment, or nil
+func resolveLink(base *url.URL, href string) *url.URL {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return nil
+	}
+	u, err := base.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,227 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Authentication methods of a crawl
+const (
+	AuthBasic   = "basic"   // HTTP Basic credentials
+	AuthBearer  = "bearer"  // Authorization: Bearer token
+	AuthCookies = "cookies" // Session cookies set before the crawl
+	AuthForm    = "form"    // Login form submitted before the crawl
+)
+
+// Auth holds the credentials of a site behind a login. They are sent to the crawled site only,
+// stored encrypted and never returned by the API.
+type Auth struct {
+	Type     string       `json:"type"`
+	Username string       `json:"username,omitempty"` // basic
+	Password string       `json:"password,omitempty"` // basic
+	Token    string       `json:"token,omitempty"`    // bearer
+	Cookies  []AuthCookie `json:"cookies,omitempty"`  // cookies
+	Form     *FormLogin   `json:"form,omitempty"`     // form
+}
+
+// AuthCookie is a cookie set on the crawled site
+type AuthCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Path  string `json:"path,omitempty"`
+}
+
+// FormLogin describes a login form. Hidden fields of the form (e.g. CSRF tokens) are submitted too.
+type FormLogin struct {
+	URL           string            `json:"url"`            // Page of the login form
+	UsernameField string            `json:"username_field"` // Defaults to "username"
+	PasswordField string            `json:"password_field"` // Defaults to "password"
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	Fields        map[string]string `json:"fields,omitempty"` // Additional fields to submit
+}
+
+// Validate checks the credentials for a crawl of seed and fills defaults
+func (a *Auth) Validate(seed *url.URL) error {
+	switch a.Type {
+	case AuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("basic authentication requires a username")
+		}
+	case AuthBearer:
+		if a.Token == "" || strings.ContainsAny(a.Token, "\r\n") {
+			return fmt.Errorf("bearer authentication requires a single-line token")
+		}
+	case AuthCookies:
+		if len(a.Cookies) == 0 {
+			return fmt.Errorf("cookie authentication requires at least one cookie")
+		}
+		for _, cookie := range a.Cookies {
+			if cookie.Name == "" || !validHeaderName(cookie.Name) || strings.ContainsAny(cookie.Value, "\r\n;") {
+				return fmt.Errorf("invalid cookie %q", cookie.Name)
+			}
+		}
+	case AuthForm:
+		if a.Form == nil || a.Form.URL == "" || a.Form.Username == "" || a.Form.Password == "" {
+			return fmt.Errorf("form login requires url, username and password")
+		}
+		loginURL, err := url.Parse(a.Form.URL)
+		if err != nil || (loginURL.Scheme != "http" && loginURL.Scheme != "https") {
+			return fmt.Errorf("form login url must be an http or https URL")
+		}
+		if !credentialTarget(seed, loginURL) {
+			return fmt.Errorf("form login url must be on the crawled site, and use https when the crawl does")
+		}
+		if a.Form.UsernameField == "" {
+			a.Form.UsernameField = "username"
+		}
+		if a.Form.PasswordField == "" {
+			a.Form.PasswordField = "password"
+		}
+	default:
+		return fmt.Errorf("auth type must be basic, bearer, cookies or form")
+	}
+	return nil
+}
+
+// authorize adds the credentials to a request for the crawled site that is not downgraded to plain http
+func (a *Auth) authorize(req *http.Request, seed *url.URL) {
+	if a == nil || !credentialTarget(seed, req.URL) {
+		return
+	}
+	switch a.Type {
+	case AuthBasic:
+		req.SetBasicAuth(a.Username, a.Password)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+}
+
+// cookies returns the cookies to seed the session's jar with. Secure cookies are only sent
+// over https.
+func (a *Auth) cookies(secure bool) []*http.Cookie {
+	if a == nil || a.Type != AuthCookies {
+		return nil
+	}
+	cookies := make([]*http.Cookie, 0, len(a.Cookies))
+	for _, c := range a.Cookies {
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value, Path: path, Secure: secure})
+	}
+	return cookies
+}
+
+// login submits the login form; the session cookies it receives end up in the session's jar
+func (s *session) login(ctx context.Context) error {
+	form := s.auth.Form
+	fields := url.Values{}
+	loginURL, _ := url.Parse(form.URL)
+	action := loginURL
+
+	// Load the form for its action and hidden fields
+	req, err := s.newRequest(ctx, http.MethodGet, form.URL)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if !credentialTarget(s.seed, resp.Request.URL) {
+		resp.Body.Close()
+		return fmt.Errorf("login page redirected to %s, outside the crawled site", resp.Request.URL.Redacted())
+	}
+	if resp.StatusCode < 400 {
+		if found, hidden := findLoginForm(resp.Request.URL, io.LimitReader(resp.Body, maxBodySize), form.PasswordField); found != nil {
+			action, fields = found, hidden
+		}
+	}
+	resp.Body.Close()
+	// The credentials are only ever posted to the crawled site, and never over plain http when the crawl uses https
+	if (action.Scheme != "http" && action.Scheme != "https") || !credentialTarget(s.seed, action) {
+		return fmt.Errorf("login form posts to %s, outside the crawled site", action.Redacted())
+	}
+
+	for name, value := range form.Fields {
+		fields.Set(name, value)
+	}
+	fields.Set(form.UsernameField, form.Username)
+	fields.Set(form.PasswordField, form.Password)
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, action.String(), strings.NewReader(fields.Encode()))
+	if err != nil {
+		return err
+	}
+	s.options.apply(req, s.seed)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err = s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login returned HTTP %d", resp.StatusCode)
+	}
+	// Landing on the login form again means the credentials were rejected
+	landing := resp.Request.URL.Path
+	if analysis, err := Analyze(resp.Request.URL, io.LimitReader(resp.Body, maxBodySize)); err == nil &&
+		analysis.HasLoginForm && (landing == action.Path || landing == loginURL.Path) {
+		return fmt.Errorf("credentials were rejected")
+	}
+	return nil
+}
+
+// findLoginForm looks for the form containing the password field and returns its action and hidden fields
+func findLoginForm(base *url.URL, r io.Reader, passwordField string) (*url.URL, url.Values) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, nil
+	}
+
+	var action *url.URL
+	var hidden url.Values
+	var walk func(n *html.Node, form *html.Node, fields url.Values) bool
+	walk = func(n *html.Node, form *html.Node, fields url.Values) bool {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "form":
+				form, fields = n, url.Values{}
+			case "input":
+				if form != nil {
+					name := attr(n, "name")
+					if strings.EqualFold(attr(n, "type"), "hidden") && name != "" {
+						fields.Set(name, attr(n, "value"))
+					}
+					if name == passwordField {
+						action = base
+						if target := attr(form, "action"); target != "" {
+							if resolved, err := base.Parse(target); err == nil {
+								action = resolved
+							}
+						}
+						hidden = fields
+					}
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if walk(child, form, fields) {
+				return true
+			}
+		}
+		// The form is complete once its subtree is walked
+		return n.Type == html.ElementNode && n.Data == "form" && action != nil
+	}
+	walk(doc, nil, nil)
+	return action, hidden
+}
@@ -0,0 +1,77 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCredentialsStayOnCrawledSite(t *testing.T) {
+	options, err := Options{Headers: map[string]string{"X-Api-Key": "key"}}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auths := map[string]*Auth{
+		AuthBasic:   {Type: AuthBasic, Username: "user", Password: "secret"},
+		AuthBearer:  {Type: AuthBearer, Token: "token"},
+		AuthCookies: {Type: AuthCookies, Cookies: []AuthCookie{{Name: "session", Value: "secret"}}},
+	}
+	tests := []struct {
+		seed   string
+		target string
+		want   bool
+	}{
+		{"https://example.com/", "https://example.com/page", true},
+		{"http://example.com/", "http://example.com/page", true},
+		{"http://example.com/", "https://example.com/page", true},
+		{"https://example.com/", "http://example.com/page", false},
+		{"https://example.com/", "https://other.com/page", false},
+	}
+	for authType, auth := range auths {
+		for _, tt := range tests {
+			t.Run(authType+" "+tt.seed+" to "+tt.target, func(t *testing.T) {
+				seed, _ := url.Parse(tt.seed)
+				s := (&Crawler{transport: http.DefaultTransport}).newSession(options, seed, auth)
+				req, err := s.newRequest(context.Background(), http.MethodGet, tt.target)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sent := map[string]bool{
+					"custom header": req.Header.Get("X-Api-Key") != "",
+					"credentials":   req.Header.Get("Authorization") != "" || len(s.client.Jar.Cookies(req.URL)) > 0,
+				}
+				for what, got := range sent {
+					if got != tt.want {
+						t.Errorf("%s sent = %v, want %v", what, got, tt.want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRedirectDowngradeDropsCredentials(t *testing.T) {
+	options, err := Options{Headers: map[string]string{"X-Api-Key": "key"}}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, _ := url.Parse("https://example.com/")
+	s := (&Crawler{transport: http.DefaultTransport}).newSession(options, seed, &Auth{Type: AuthBearer, Token: "token"})
+
+	first, _ := s.newRequest(context.Background(), http.MethodGet, "https://example.com/old")
+	// The client copies the first request's headers to the redirect before checking it
+	redirect, _ := http.NewRequest(http.MethodGet, "http://example.com/new", nil)
+	redirect.Header = first.Header.Clone()
+	if err := s.client.CheckRedirect(redirect, []*http.Request{first}); err != nil {
+		t.Fatalf("CheckRedirect() error = %v", err)
+	}
+	if redirect.Header.Get("Authorization") != "" || redirect.Header.Get("X-Api-Key") != "" {
+		t.Errorf("plain http redirect kept credentials: %v", redirect.Header)
+	}
+
+	post, _ := http.NewRequest(http.MethodPost, "http://example.com/login", nil)
+	if err := s.client.CheckRedirect(post, []*http.Request{first}); err == nil {
+		t.Error("CheckRedirect() reposted a login form over plain http")
+	}
+}
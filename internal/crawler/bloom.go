@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter is a fixed-size probabilistic set: it never forgets an added key and
+// reports an unseen key as present with the configured false positive rate.
+// Its memory depends only on the expected number of keys, not on their length.
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64 // Number of bits
+	hashes uint64 // Number of bit positions per key
+}
+
+// newBloomFilter sizes a filter for n keys at false positive rate p
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	size := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Round(float64(size) / float64(n) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &bloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// add inserts a key and reports whether it may have been present already
+func (f *bloomFilter) add(key string) bool {
+	h1, h2 := bloomHashes(key)
+	present := true
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if f.bits[word]&mask == 0 {
+			present = false
+			f.bits[word] |= mask
+		}
+	}
+	return present
+}
+
+// reset forgets every key
+func (f *bloomFilter) reset() {
+	clear(f.bits)
+}
+
+// sizeBytes returns the memory used by the bit array
+func (f *bloomFilter) sizeBytes() int {
+	return len(f.bits) * 8
+}
+
+// bloomHashes derives the two base hashes of double hashing (h1 + i*h2)
+func bloomHashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1 := sum & 0xffffffff
+	h2 := sum>>32 | 1 // Never zero, or every probe would hit the same bit
+	return h1, h2
+}
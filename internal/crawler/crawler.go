@@ -0,0 +1,669 @@
+// Package crawler fetches and analyzes the pages of a crawl.
+package crawler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/netguard"
+	"webcrawler-backend/internal/urlcanon"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// userAgent identifies the crawler to the sites it fetches
+	userAgent = "WebCrawler/1.0 (+https://github.com/MadinaKon/crawler-tech-challenge)"
+	// maxBodySize is the largest HTML document analyzed
+	maxBodySize = 5 << 20
+	// linkCheckConcurrency is the number of links checked in parallel on a page
+	linkCheckConcurrency = 5
+	// linksPerPage is the expected number of distinct links per page, used to size the visited sets
+	linksPerPage = 100
+)
+
+var (
+	// errInterrupted means the crawl was paused, stopped or deleted while running
+	errInterrupted = errors.New("crawl interrupted")
+	// errDisallowed means robots.txt does not allow the crawler to fetch a page
+	errDisallowed = errors.New("disallowed by robots.txt")
+)
+
+// Crawler runs crawls: it walks the site from the seed URL up to the crawl's page budget,
+// records every page, checks links, and keeps its frontier in the database so a paused
+// or interrupted crawl resumes where it stopped.
+type Crawler struct {
+	db        *gorm.DB
+	transport http.RoundTripper
+	canon     *urlcanon.Canonicalizer
+}
+
+// New creates a crawler whose connections go through the SSRF guard and which canonicalizes
+// the URLs it discovers
+func New(db *gorm.DB, guard *netguard.Guard, canon *urlcanon.Canonicalizer) *Crawler {
+	return &Crawler{
+		db:        db,
+		transport: guard.Transport(),
+		canon:     canon,
+	}
+}
+
+// session is the HTTP configuration of one crawl run
+type session struct {
+	client  *http.Client
+	options Options
+	robots  *robotsCache
+	seed    *url.URL
+	auth    *Auth
+
+	mu        sync.Mutex
+	redirects []*models.RedirectChain // Recorded since the last page was stored
+}
+
+// newSession builds the client of a crawl from its options and credentials
+func (c *Crawler) newSession(options Options, seed *url.URL, auth *Auth) *session {
+	jar, _ := cookiejar.New(nil)
+	jar.SetCookies(seed, auth.cookies(seed.Scheme == "https"))
+	client := &http.Client{
+		Transport: &tracingTransport{base: c.transport},
+		Timeout:   options.timeout(),
+		Jar:       jar,
+	}
+	s := &session{client: client, options: options, robots: newRobotsCache(), seed: seed, auth: auth}
+	client.CheckRedirect = s.checkRedirect
+	if !*options.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return s
+}
+
+// checkRedirect limits redirect chains. The client copies the headers of the first request
+// to every redirect, so credentials and custom headers are removed when a redirect leaves
+// the crawled site or downgrades to plain http, and a login form is not posted there again
+// by a 307 or 308.
+func (s *session) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !credentialTarget(s.seed, req.URL) {
+		if req.Method == http.MethodPost {
+			return fmt.Errorf("login redirected to %s, outside the crawled site", req.URL.Redacted())
+		}
+		req.Header.Del("Authorization")
+		for name := range s.options.Headers {
+			req.Header.Del(name)
+		}
+	}
+	return checkRedirect(req, via)
+}
+
+// newRequest creates a request carrying the crawl's user agent and headers
+func (s *session) newRequest(ctx context.Context, method, target string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.options.apply(req, s.seed)
+	s.auth.authorize(req, s.seed)
+	return req, nil
+}
+
+// recordRedirects keeps the redirect chain of a traced request until the page is stored
+func (s *session) recordRedirects(source, requested string, trace *redirectTrace, err error) {
+	if chain := redirectChain(source, requested, trace, err); chain != nil {
+		s.mu.Lock()
+		s.redirects = append(s.redirects, chain)
+		s.mu.Unlock()
+	}
+}
+
+// takeRedirects returns the redirect chains recorded since the last call
+func (s *session) takeRedirects() []*models.RedirectChain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chains := s.redirects
+	s.redirects = nil
+	return chains
+}
+
+// credentials decodes the credentials of a crawl (decrypted when it was loaded), if it has any
+func (c *Crawler) credentials(crawl *models.CrawlResult) (*Auth, error) {
+	if len(crawl.Credentials) == 0 {
+		return nil, nil
+	}
+	var auth Auth
+	if err := json.Unmarshal(crawl.Credentials, &auth); err != nil {
+		return nil, fmt.Errorf("cannot decode credentials: %v", err)
+	}
+	return &auth, nil
+}
+
+// Run processes a crawl that was claimed (status running) and records its final status
+func (c *Crawler) Run(ctx context.Context, crawl *models.CrawlResult) {
+	err := c.crawl(ctx, crawl)
+	switch {
+	case errors.Is(err, errInterrupted):
+		// Paused crawls keep their frontier; stopped ones are finished
+		c.finishInterrupted(crawl.ID)
+	case err != nil:
+		log.Printf("[WARN] Crawl %d failed: %v", crawl.ID, err)
+		c.db.Model(&models.CrawlResult{}).Where("id = ? AND status = ?", crawl.ID, models.StatusRunning).
+			Updates(map[string]interface{}{
+				"status":        models.StatusError,
+				"error_message": err.Error(),
+			})
+	default:
+		result := c.db.Model(&models.CrawlResult{}).Where("id = ? AND status = ?", crawl.ID, models.StatusRunning).
+			Updates(map[string]interface{}{
+				"status":   models.StatusDone,
+				"progress": 100,
+			})
+		if result.Error == nil && result.RowsAffected > 0 {
+			NewDBFrontier(c.db, crawl.ID, 0).Clear()
+		}
+	}
+	// Tell a pause that the crawl is no longer running, so it can be resumed safely
+	c.db.Model(&models.CrawlResult{}).Where("id = ? AND status = ? AND paused_at IS NULL", crawl.ID, models.StatusPaused).
+		Update("paused_at", time.Now())
+}
+
+// Reset discards the pages, broken links and frontier of a crawl so it starts over
+func Reset(db *gorm.DB, crawlID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := NewDBFrontier(tx, crawlID, 0).Clear(); err != nil {
+			return err
+		}
+		if err := tx.Where("crawl_result_id = ?", crawlID).Delete(&models.CrawlPage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("crawl_result_id = ?", crawlID).Delete(&models.BrokenLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("crawl_result_id = ?", crawlID).Delete(&models.CrawlIssue{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("crawl_result_id = ?", crawlID).Delete(&models.RedirectChain{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CrawlResult{}).Where("id = ?", crawlID).Updates(map[string]interface{}{
+			"progress":                  0,
+			"pages_crawled":             0,
+			"internal_links":            0,
+			"external_links":            0,
+			"inaccessible_links":        0,
+			"has_login_form":            false,
+			"has_classified_login_form": false,
+			"has_signup_form":           false,
+			"has_password_reset_form":   false,
+			"has_search_form":           false,
+			"has_newsletter_form":       false,
+			"has_payment_form":          false,
+			"has_contact_form":          false,
+			"skipped_urls":              nil,
+			"issue_counts":              nil,
+			"error_message":             "",
+		}).Error
+	})
+}
+
+func (c *Crawler) crawl(ctx context.Context, crawl *models.CrawlResult) error {
+	seed, err := url.Parse(crawl.URL)
+	if err == nil {
+		seed, err = c.canon.Canonicalize(seed)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+
+	maxPages := crawl.MaxPages
+	if maxPages < 1 {
+		maxPages = 1
+	}
+
+	options := DefaultOptions()
+	if !crawl.Options.IsNull() {
+		var stored Options
+		if err := json.Unmarshal(crawl.Options, &stored); err != nil {
+			return fmt.Errorf("invalid options: %v", err)
+		}
+		if options, err = stored.Normalize(); err != nil {
+			return fmt.Errorf("invalid options: %v", err)
+		}
+	}
+	auth, err := c.credentials(crawl)
+	if err != nil {
+		return err
+	}
+	s := c.newSession(options, seed, auth)
+	if auth != nil && auth.Type == AuthForm {
+		if err := s.login(ctx); err != nil {
+			return fmt.Errorf("login failed: %v", err)
+		}
+	}
+	maxDepth := *options.MaxDepth
+
+	var scope Scope
+	if !crawl.Scope.IsNull() {
+		if err := json.Unmarshal(crawl.Scope, &scope); err != nil {
+			return fmt.Errorf("invalid scope: %v", err)
+		}
+	}
+	rules, err := scope.Compile(seed)
+	if err != nil {
+		return fmt.Errorf("invalid scope: %v", err)
+	}
+	skipped := make(map[string]int)
+	if !crawl.SkippedURLs.IsNull() {
+		json.Unmarshal(crawl.SkippedURLs, &skipped)
+	}
+	issueCounts := make(map[string]int)
+	if !crawl.IssueCounts.IsNull() {
+		json.Unmarshal(crawl.IssueCounts, &issueCounts)
+	}
+
+	// A new crawl starts from the seed; a resumed one continues with its frontier,
+	// which already holds the seed
+	frontier := c.frontier(crawl.ID, maxPages)
+	if _, err := frontier.Add([]FrontierEntry{{URL: seed.String()}}); err != nil {
+		return err
+	}
+
+	checked := newLinkSet(maxPages * linksPerPage)
+	filtered := newLinkSet(maxPages * linksPerPage) // Out-of-scope URLs already counted
+	pagesCrawled := crawl.PagesCrawled
+	for pagesCrawled < maxPages {
+		if err := c.checkStatus(ctx, crawl.ID); err != nil {
+			return err
+		}
+
+		next, err := frontier.Next()
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			break
+		}
+
+		page, analysis, err := c.fetchPage(ctx, s, next.URL)
+		if err != nil && pagesCrawled == 0 && next.Depth == 0 {
+			// The seed URL itself cannot be crawled; keep its redirects, e.g. a loop
+			if storeErr := c.db.Transaction(func(tx *gorm.DB) error {
+				if err := storeRedirects(tx, crawl.ID, s.takeRedirects(), issueCounts); err != nil || len(issueCounts) == 0 {
+					return err
+				}
+				counts, _ := json.Marshal(issueCounts)
+				return tx.Model(&models.CrawlResult{}).Where("id = ?", crawl.ID).Update("issue_counts", models.JSON(counts)).Error
+			}); storeErr != nil {
+				log.Printf("[WARN] Failed to store the redirects of crawl %d: %v", crawl.ID, storeErr)
+			}
+			return err
+		}
+		if err == errDisallowed {
+			// Not fetched, so it does not use the page budget
+			skipped[SkipRobots]++
+			counts, _ := json.Marshal(skipped)
+			if err := c.db.Model(&models.CrawlResult{}).Where("id = ?", crawl.ID).Update("skipped_urls", models.JSON(counts)).Error; err != nil {
+				return err
+			}
+			if err := frontier.Done(next); err != nil {
+				return err
+			}
+			continue
+		}
+		page.CrawlResultID = crawl.ID
+		page.Depth = next.Depth
+
+		var internal []*url.URL
+		if analysis != nil {
+			for _, link := range analysis.Links {
+				if sameSite(seed, link) {
+					internal = append(internal, link)
+				}
+			}
+			page.InternalLinks = len(internal)
+			page.ExternalLinks = len(analysis.Links) - len(internal)
+			page.HasLoginForm = analysis.HasLoginForm
+			page.Forms, _ = json.Marshal(analysis.Forms)
+		}
+
+		// Links in scope are followed, the others counted once per URL by reason
+		var follow []FrontierEntry
+		if analysis != nil && next.Depth < maxDepth {
+			for _, link := range analysis.Links {
+				target, reason := rules.Apply(link)
+				if reason != "" {
+					if filtered.add(link.String()) {
+						skipped[reason]++
+					}
+					continue
+				}
+				// Stripping parameters may have changed the query's encoding
+				if target, err := c.canon.Canonicalize(target); err == nil {
+					follow = append(follow, FrontierEntry{URL: target.String(), Depth: next.Depth + 1})
+				}
+			}
+		}
+
+		broken := c.checkLinks(ctx, s, crawl.ID, seed, analysis, checked)
+		pagesCrawled++
+
+		if err := c.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(page).Error; err != nil {
+				return err
+			}
+			updates := map[string]interface{}{
+				"pages_crawled":      pagesCrawled,
+				"progress":           pagesCrawled * 100 / maxPages,
+				"internal_links":     gorm.Expr("internal_links + ?", page.InternalLinks),
+				"external_links":     gorm.Expr("external_links + ?", page.ExternalLinks),
+				"inaccessible_links": gorm.Expr("inaccessible_links + ?", broken),
+			}
+			if page.HasLoginForm {
+				updates["has_login_form"] = true
+			}
+			if analysis != nil {
+				for _, form := range analysis.Forms {
+					if form.Class != models.FormOther {
+						updates[models.FormColumn(form.Class)] = true
+					}
+				}
+			}
+			if err := storeRedirects(tx, crawl.ID, s.takeRedirects(), issueCounts); err != nil {
+				return err
+			}
+			if len(issueCounts) > 0 {
+				counts, _ := json.Marshal(issueCounts)
+				updates["issue_counts"] = models.JSON(counts)
+			}
+			if len(skipped) > 0 {
+				counts, _ := json.Marshal(skipped)
+				updates["skipped_urls"] = models.JSON(counts)
+			}
+			if next.Depth == 0 && analysis != nil {
+				// The seed page describes the crawl
+				headings, _ := json.Marshal(analysis.HeadingCounts)
+				updates["title"] = truncate(analysis.Title, 500)
+				updates["html_version"] = analysis.Doctype.Version
+				updates["document_mode"] = analysis.Doctype.Mode
+				updates["html_version_confidence"] = analysis.Doctype.Confidence
+				updates["heading_counts"] = models.JSON(headings)
+			}
+			return tx.Model(&models.CrawlResult{}).Where("id = ?", crawl.ID).Updates(updates).Error
+		}); err != nil {
+			return err
+		}
+		// Interrupted before this point, the page is visited again on resume
+		if err := frontier.Done(next); err != nil {
+			return err
+		}
+
+		if _, err := frontier.Add(follow); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkStatus returns errInterrupted when the crawl is no longer running
+func (c *Crawler) checkStatus(ctx context.Context, crawlID uint) error {
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	var status []models.CrawlStatus
+	if err := c.db.Model(&models.CrawlResult{}).Where("id = ?", crawlID).Pluck("status", &status).Error; err != nil {
+		return err
+	}
+	if len(status) == 0 || status[0] != models.StatusRunning {
+		return errInterrupted
+	}
+	return nil
+}
+
+// finishInterrupted discards the frontier of stopped and deleted crawls. Paused crawls keep it,
+// and crawls interrupted by shutdown are requeued on the next start.
+func (c *Crawler) finishInterrupted(crawlID uint) {
+	var status []models.CrawlStatus
+	c.db.Model(&models.CrawlResult{}).Where("id = ?", crawlID).Pluck("status", &status)
+	if len(status) == 0 || status[0] == models.StatusStopped {
+		NewDBFrontier(c.db, crawlID, 0).Clear()
+	}
+}
+
+// frontier returns the persisted frontier of a crawl
+func (c *Crawler) frontier(crawlID uint, maxPages int) Frontier {
+	return NewDBFrontier(c.db, crawlID, maxPages*linksPerPage)
+}
+
+// fetchPage downloads and analyzes a page. Non-HTML pages are recorded without analysis.
+// Pages disallowed by robots.txt are recorded with errDisallowed and not fetched.
+func (c *Crawler) fetchPage(ctx context.Context, s *session, pageURL string) (*models.CrawlPage, *PageAnalysis, error) {
+	page := &models.CrawlPage{URL: pageURL}
+
+	req, err := s.newRequest(ctx, http.MethodGet, pageURL)
+	if err != nil {
+		page.ErrorMessage = err.Error()
+		return page, nil, err
+	}
+	if *s.options.RespectRobots && !s.robots.allowed(ctx, s, req.URL) {
+		page.ErrorMessage = errDisallowed.Error()
+		return page, nil, errDisallowed
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+	}
+
+	traceCtx, trace := withRedirectTrace(ctx)
+	resp, err := s.client.Do(req.WithContext(traceCtx))
+	s.recordRedirects(models.RedirectSourcePage, pageURL, trace, err)
+	if err != nil {
+		page.ErrorMessage = err.Error()
+		return page, nil, err
+	}
+	defer resp.Body.Close()
+
+	page.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		err := fmt.Errorf("HTTP %d", resp.StatusCode)
+		page.ErrorMessage = err.Error()
+		return page, nil, err
+	}
+	if resp.StatusCode >= 300 {
+		// Redirect not followed (follow_redirects is off)
+		return page, nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return page, nil, nil
+	}
+
+	analysis, err := Analyze(resp.Request.URL, io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		page.ErrorMessage = err.Error()
+		return page, nil, err
+	}
+	page.Title = truncate(analysis.Title, 500)
+	analysis.Links = c.canonicalLinks(analysis.Links)
+	return page, analysis, nil
+}
+
+// canonicalLinks canonicalizes links so that different spellings of a URL are classified,
+// checked and queued once
+func (c *Crawler) canonicalLinks(links []*url.URL) []*url.URL {
+	seen := make(map[string]bool, len(links))
+	canonical := links[:0]
+	for _, link := range links {
+		u, err := c.canon.Canonicalize(link)
+		if err != nil || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		canonical = append(canonical, u)
+	}
+	return canonical
+}
+
+// checkLinks checks the page's links not checked yet in this run, stores the broken ones
+// and returns how many were broken
+// (links to other sites only when check_external_links is on)
+func (c *Crawler) checkLinks(ctx context.Context, s *session, crawlID uint, seed *url.URL, analysis *PageAnalysis, checked *linkSet) int {
+	if analysis == nil {
+		return 0
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		broken []models.BrokenLink
+		slots  = make(chan struct{}, linkCheckConcurrency)
+	)
+	for _, link := range analysis.Links {
+		if !*s.options.CheckExternalLinks && !sameSite(seed, link) {
+			continue
+		}
+		if !checked.add(link.String()) {
+			continue
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(link string) {
+			defer func() { <-slots; wg.Done() }()
+			if b := s.checkLink(ctx, link); b != nil {
+				b.CrawlResultID = crawlID
+				mu.Lock()
+				broken = append(broken, *b)
+				mu.Unlock()
+			}
+		}(link.String())
+	}
+	wg.Wait()
+
+	// A resumed crawl may check a link again; keep one record per URL
+	stored := 0
+	for _, b := range broken {
+		var count int64
+		c.db.Model(&models.BrokenLink{}).Where("crawl_result_id = ? AND url = ?", crawlID, b.URL).Count(&count)
+		if count > 0 {
+			continue
+		}
+		if err := c.db.Omit("CrawlResult").Create(&b).Error; err != nil {
+			log.Printf("[WARN] Failed to store broken link %s of crawl %d: %v", b.URL, crawlID, err)
+			continue
+		}
+		stored++
+	}
+	return stored
+}
+
+// checkLink requests a link and returns a broken link record when it is inaccessible
+func (s *session) checkLink(ctx context.Context, link string) *models.BrokenLink {
+	if len(link) > 500 {
+		return nil // Does not fit the broken_links table
+	}
+
+	status, err := s.linkStatus(ctx, http.MethodHead, link)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		// Some servers do not support HEAD
+		status, err = s.linkStatus(ctx, http.MethodGet, link)
+	}
+
+	switch {
+	case err != nil:
+		var blocked *netguard.BlockedError
+		errorType := "network_error"
+		if errors.As(err, &blocked) {
+			errorType = "blocked"
+		} else if errors.Is(err, errTooManyRedirects) {
+			errorType = "too_many_redirects"
+		} else if errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "Client.Timeout") {
+			errorType = "timeout"
+		}
+		return &models.BrokenLink{URL: link, ErrorType: errorType, ErrorMessage: err.Error()}
+	case status >= 400:
+		return &models.BrokenLink{URL: link, StatusCode: status, ErrorType: fmt.Sprint(status), ErrorMessage: http.StatusText(status)}
+	}
+	return nil
+}
+
+func (s *session) linkStatus(ctx context.Context, method, link string) (int, error) {
+	req, err := s.newRequest(ctx, method, link)
+	if err != nil {
+		return 0, err
+	}
+	traceCtx, trace := withRedirectTrace(ctx)
+	resp, err := s.client.Do(req.WithContext(traceCtx))
+	if err != nil {
+		s.recordRedirects(models.RedirectSourceLink, link, trace, err)
+		return 0, err
+	}
+	if method == http.MethodGet || (resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented) {
+		// A HEAD rejected by the server is retried with GET, which is recorded instead
+		s.recordRedirects(models.RedirectSourceLink, link, trace, nil)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// sameSite reports whether a link stays on the seed's host (ignoring a leading "www.")
+func sameSite(seed, link *url.URL) bool {
+	return strings.TrimPrefix(strings.ToLower(seed.Hostname()), "www.") ==
+		strings.TrimPrefix(strings.ToLower(link.Hostname()), "www.")
+}
+
+// credentialTarget reports whether a request may carry the crawl's credentials and custom
+// headers: it stays on the seed's site, and uses the seed's scheme or upgrades to https.
+// A crawl seeded with https never sends them over plain http.
+func credentialTarget(seed, link *url.URL) bool {
+	return sameSite(seed, link) && (strings.EqualFold(link.Scheme, seed.Scheme) || strings.EqualFold(link.Scheme, "https"))
+}
+
+func hashURL(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// linkSet is a concurrency-safe set of links. It keeps a 128-bit hash of each link
+// instead of the link itself, so its memory stays small on large sites, and unlike
+// a Bloom filter it never mistakes a new link for one already in the set.
+type linkSet struct {
+	mu   sync.Mutex
+	seen map[[16]byte]struct{}
+}
+
+func newLinkSet(expected int) *linkSet {
+	return &linkSet{seen: make(map[[16]byte]struct{}, min(expected, 1024))}
+}
+
+// add returns false when the link was already in the set
+func (s *linkSet) add(u string) bool {
+	sum := sha256.Sum256([]byte(u))
+	key := [16]byte(sum[:16])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[key]; ok {
+		return false
+	}
+	s.seen[key] = struct{}{}
+	return true
+}
@@ -0,0 +1,311 @@
+package crawler
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+)
+
+// Document modes, as browsers render a page
+const (
+	ModeNoQuirks      = "no-quirks"
+	ModeLimitedQuirks = "limited-quirks"
+	ModeQuirks        = "quirks"
+)
+
+// doctypeScanLimit is how far into a document the doctype is looked for
+const doctypeScanLimit = 64 << 10
+
+// Doctype is the HTML version a document declares and how sure the detection is
+type Doctype struct {
+	Version    string  // e.g. HTML5, HTML 4.01 Transitional, XHTML 1.1; "unknown" without a known doctype
+	Mode       string  // no-quirks, limited-quirks or quirks
+	Confidence float64 // 0-1: 1 for an exact doctype, lower when the version is inferred
+}
+
+// knownDoctype is a public identifier with its version and official system identifier
+type knownDoctype struct {
+	version string
+	system  string
+}
+
+// knownDoctypes are keyed by lowercase public identifier
+var knownDoctypes = map[string]knownDoctype{
+	"-//w3c//dtd html 4.01//en":              {"HTML 4.01 Strict", "http://www.w3.org/tr/html4/strict.dtd"},
+	"-//w3c//dtd html 4.01 transitional//en": {"HTML 4.01 Transitional", "http://www.w3.org/tr/html4/loose.dtd"},
+	"-//w3c//dtd html 4.01 frameset//en":     {"HTML 4.01 Frameset", "http://www.w3.org/tr/html4/frameset.dtd"},
+	"-//w3c//dtd html 4.0//en":               {"HTML 4.0 Strict", "http://www.w3.org/tr/rec-html40/strict.dtd"},
+	"-//w3c//dtd html 4.0 transitional//en":  {"HTML 4.0 Transitional", "http://www.w3.org/tr/rec-html40/loose.dtd"},
+	"-//w3c//dtd html 4.0 frameset//en":      {"HTML 4.0 Frameset", "http://www.w3.org/tr/rec-html40/frameset.dtd"},
+	"-//w3c//dtd xhtml 1.0 strict//en":       {"XHTML 1.0 Strict", "http://www.w3.org/tr/xhtml1/dtd/xhtml1-strict.dtd"},
+	"-//w3c//dtd xhtml 1.0 transitional//en": {"XHTML 1.0 Transitional", "http://www.w3.org/tr/xhtml1/dtd/xhtml1-transitional.dtd"},
+	"-//w3c//dtd xhtml 1.0 frameset//en":     {"XHTML 1.0 Frameset", "http://www.w3.org/tr/xhtml1/dtd/xhtml1-frameset.dtd"},
+	"-//w3c//dtd xhtml 1.1//en":              {"XHTML 1.1", "http://www.w3.org/tr/xhtml11/dtd/xhtml11.dtd"},
+	"-//w3c//dtd xhtml basic 1.0//en":        {"XHTML Basic 1.0", "http://www.w3.org/tr/xhtml-basic/xhtml-basic10.dtd"},
+	"-//w3c//dtd xhtml basic 1.1//en":        {"XHTML Basic 1.1", "http://www.w3.org/tr/xhtml-basic/xhtml-basic11.dtd"},
+	"-//w3c//dtd xhtml+rdfa 1.0//en":         {"XHTML+RDFa 1.0", "http://www.w3.org/markup/dtd/xhtml-rdfa-1.dtd"},
+	"-//wapforum//dtd xhtml mobile 1.0//en":  {"XHTML Mobile 1.0", "http://www.wapforum.org/dtd/xhtml-mobile10.dtd"},
+	"-//w3c//dtd html 3.2 final//en":         {"HTML 3.2", ""},
+	"-//w3c//dtd html 3.2//en":               {"HTML 3.2", ""},
+	"-//ietf//dtd html 2.0//en":              {"HTML 2.0", ""},
+	"-//ietf//dtd html//en":                  {"HTML 2.0", ""},
+}
+
+// quirksPublicPrefixes put a document in quirks mode, see the HTML standard, "The initial insertion mode"
+var quirksPublicPrefixes = []string{
+	"+//silmaril//dtd html pro v0r11 19970101//",
+	"-//as//dtd html 3.0 aswedit + extensions//",
+	"-//advasoft ltd//dtd html 3.0 aswedit + extensions//",
+	"-//ietf//dtd html 2.0 level 1//",
+	"-//ietf//dtd html 2.0 level 2//",
+	"-//ietf//dtd html 2.0 strict level 1//",
+	"-//ietf//dtd html 2.0 strict level 2//",
+	"-//ietf//dtd html 2.0 strict//",
+	"-//ietf//dtd html 2.0//",
+	"-//ietf//dtd html 2.1e//",
+	"-//ietf//dtd html 3.0//",
+	"-//ietf//dtd html 3.2 final//",
+	"-//ietf//dtd html 3.2//",
+	"-//ietf//dtd html 3//",
+	"-//ietf//dtd html level 0//",
+	"-//ietf//dtd html level 1//",
+	"-//ietf//dtd html level 2//",
+	"-//ietf//dtd html level 3//",
+	"-//ietf//dtd html strict level 0//",
+	"-//ietf//dtd html strict level 1//",
+	"-//ietf//dtd html strict level 2//",
+	"-//ietf//dtd html strict level 3//",
+	"-//ietf//dtd html strict//",
+	"-//ietf//dtd html//",
+	"-//metrius//dtd metrius presentational//",
+	"-//microsoft//dtd internet explorer 2.0 html strict//",
+	"-//microsoft//dtd internet explorer 2.0 html//",
+	"-//microsoft//dtd internet explorer 2.0 tables//",
+	"-//microsoft//dtd internet explorer 3.0 html strict//",
+	"-//microsoft//dtd internet explorer 3.0 html//",
+	"-//microsoft//dtd internet explorer 3.0 tables//",
+	"-//netscape comm. corp.//dtd html//",
+	"-//netscape comm. corp.//dtd strict html//",
+	"-//o'reilly and associates//dtd html 2.0//",
+	"-//o'reilly and associates//dtd html extended 1.0//",
+	"-//o'reilly and associates//dtd html extended relaxed 1.0//",
+	"-//sq//dtd html 2.0 hotmetal + extensions//",
+	"-//softquad software//dtd hotmetal pro 6.0::19990601::extensions to html 4.0//",
+	"-//softquad//dtd hotmetal pro 4.0::19971010::extensions to html 4.0//",
+	"-//spyglass//dtd html 2.0 extended//",
+	"-//sun microsystems corp.//dtd hotjava html//",
+	"-//sun microsystems corp.//dtd hotjava strict html//",
+	"-//w3c//dtd html 3 1995-03-24//",
+	"-//w3c//dtd html 3.2 draft//",
+	"-//w3c//dtd html 3.2 final//",
+	"-//w3c//dtd html 3.2//",
+	"-//w3c//dtd html 3.2s draft//",
+	"-//w3c//dtd html 4.0 frameset//",
+	"-//w3c//dtd html 4.0 transitional//",
+	"-//w3c//dtd html experimental 19960712//",
+	"-//w3c//dtd html experimental 970421//",
+	"-//w3c//dtd w3 html//",
+	"-//w3o//dtd w3 html 3.0//",
+	"-//webtechs//dtd mozilla html 2.0//",
+	"-//webtechs//dtd mozilla html//",
+}
+
+// DetectDoctype finds the doctype at the start of a document, after a byte order mark,
+// an XML declaration, comments and whitespace, and reports the declared version and the
+// mode browsers render the document in. Like browsers, it ignores a doctype after any
+// other content, which may as well be text in a script or a comment.
+func DetectDoctype(data []byte) Doctype {
+	if len(data) > doctypeScanLimit {
+		data = data[:doctypeScanLimit]
+	}
+	rest := skipProlog(strings.ToLower(documentText(data)))
+	if !strings.HasPrefix(rest, "<!doctype") {
+		return Doctype{Version: "unknown", Mode: ModeQuirks}
+	}
+
+	name, public, system, ok := parseDoctype(rest)
+	d := Doctype{Version: "unknown", Mode: ModeNoQuirks}
+	switch {
+	case name == "html" && public == "" && (system == "" || system == "about:legacy-compat"):
+		d.Version, d.Confidence = "HTML5", 1
+	case name == "html" && public == "":
+		d.Version, d.Confidence = "HTML5", 0.7 // Unexpected system identifier
+	default:
+		public = strings.Join(strings.Fields(public), " ")
+		if known, found := knownDoctypes[public]; found {
+			d.Version, d.Confidence = known.version, 1
+			if strings.HasPrefix(known.version, "XHTML") && system == "" {
+				d.Confidence = 0.9 // XHTML requires the system identifier
+			} else if system != "" && known.system != "" && schemeless(system) != schemeless(known.system) {
+				d.Confidence = 0.8 // The system identifier is of another version
+			}
+		} else if version := inferVersion(public); version != "" {
+			d.Version, d.Confidence = version, 0.6
+		}
+	}
+	d.Mode = documentMode(name, public, system, ok)
+	if !ok {
+		d.Confidence *= 0.5 // Malformed doctype
+	}
+	return d
+}
+
+// documentText returns a document as UTF-8 text without byte order mark
+func documentText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false)
+	}
+	return string(data)
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// skipProlog skips whitespace, an XML declaration or processing instructions, and comments.
+// An unterminated comment swallows the rest of the document.
+func skipProlog(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n\f")
+		var end string
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			end = "-->"
+		case strings.HasPrefix(s, "<?"):
+			end = ">"
+		default:
+			return s
+		}
+		i := strings.Index(s[2:], end)
+		if i < 0 {
+			return ""
+		}
+		s = s[2+i+len(end):]
+	}
+}
+
+// parseDoctype reads the name and identifiers of a lowercase "<!doctype ...>". ok is false
+// for a malformed doctype, which browsers render in quirks mode.
+func parseDoctype(s string) (name, public, system string, ok bool) {
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return "", "", "", false
+	}
+	// A missing space before the name is tolerated, as browsers do
+	fields := strings.TrimLeft(s[len("<!doctype"):end], " \t\r\n\f")
+	i := strings.IndexAny(fields, " \t\r\n\f")
+	if i < 0 {
+		return fields, "", "", fields != ""
+	}
+	name, fields = fields[:i], strings.TrimLeft(fields[i:], " \t\r\n\f")
+
+	quoted := func() (string, bool) {
+		fields = strings.TrimLeft(fields, " \t\r\n\f")
+		if fields == "" || (fields[0] != '"' && fields[0] != '\'') {
+			return "", false
+		}
+		end := strings.IndexByte(fields[1:], fields[0])
+		if end < 0 {
+			return "", false
+		}
+		value := fields[1 : 1+end]
+		fields = fields[2+end:]
+		return value, true
+	}
+	switch {
+	case strings.HasPrefix(fields, "public"):
+		fields = fields[len("public"):]
+		if public, ok = quoted(); !ok {
+			return name, "", "", false
+		}
+		if strings.TrimSpace(fields) != "" {
+			if system, ok = quoted(); !ok {
+				return name, public, "", false
+			}
+		}
+	case strings.HasPrefix(fields, "system"):
+		fields = fields[len("system"):]
+		if system, ok = quoted(); !ok {
+			return name, "", "", false
+		}
+	case fields != "":
+		return name, "", "", false
+	}
+	return name, public, system, true
+}
+
+// schemeless drops the scheme of a system identifier, which sites switch to https
+func schemeless(system string) string {
+	if i := strings.Index(system, "://"); i >= 0 {
+		return system[i:]
+	}
+	return system
+}
+
+// inferVersion guesses the version of an unlisted public identifier
+func inferVersion(public string) string {
+	variant := "Strict"
+	switch {
+	case strings.Contains(public, "transitional") || strings.Contains(public, "loose"):
+		variant = "Transitional"
+	case strings.Contains(public, "frameset"):
+		variant = "Frameset"
+	}
+	switch {
+	case strings.Contains(public, "xhtml 1.1"):
+		return "XHTML 1.1"
+	case strings.Contains(public, "xhtml 1.0"):
+		return "XHTML 1.0 " + variant
+	case strings.Contains(public, "html 4.01"):
+		return "HTML 4.01 " + variant
+	case strings.Contains(public, "html 4.0"):
+		return "HTML 4.0 " + variant
+	case strings.Contains(public, "html 3.2"):
+		return "HTML 3.2"
+	case strings.Contains(public, "html 2.0"):
+		return "HTML 2.0"
+	}
+	return ""
+}
+
+// documentMode applies the quirks mode rules of the HTML standard
+func documentMode(name, public, system string, ok bool) string {
+	if !ok || name != "html" {
+		return ModeQuirks
+	}
+	switch public {
+	case "-//w3o//dtd w3 html strict 3.0//en//", "-/w3c/dtd html 4.0 transitional/en", "html":
+		return ModeQuirks
+	}
+	if system == "http://www.ibm.com/data/dtd/v11/ibmxhtml1-transitional.dtd" {
+		return ModeQuirks
+	}
+	for _, prefix := range quirksPublicPrefixes {
+		if strings.HasPrefix(public, prefix) {
+			return ModeQuirks
+		}
+	}
+	html401Loose := strings.HasPrefix(public, "-//w3c//dtd html 4.01 frameset//") ||
+		strings.HasPrefix(public, "-//w3c//dtd html 4.01 transitional//")
+	if html401Loose && system == "" {
+		return ModeQuirks
+	}
+	if html401Loose || strings.HasPrefix(public, "-//w3c//dtd xhtml 1.0 frameset//") ||
+		strings.HasPrefix(public, "-//w3c//dtd xhtml 1.0 transitional//") {
+		return ModeLimitedQuirks
+	}
+	return ModeNoQuirks
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"bytes"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectDoctype(t *testing.T) {
+	tests := []struct {
+		file       string
+		version    string
+		mode       string
+		confidence float64
+		title      string
+	}{
+		{"html5.html", "HTML5", ModeNoQuirks, 1, "HTML5"},
+		{"legacy_compat.html", "HTML5", ModeNoQuirks, 1, "Legacy compat"},
+		{"utf8_bom.html", "HTML5", ModeNoQuirks, 1, "BOM"},
+		{"utf16le_bom.html", "HTML5", ModeNoQuirks, 1, "UTF-16"},
+		{"xml_prolog.xhtml", "XHTML 1.0 Strict", ModeNoQuirks, 1, "XML prolog"},
+		{"comments.html", "HTML5", ModeNoQuirks, 1, "Comments"},
+		{"html401_strict.html", "HTML 4.01 Strict", ModeNoQuirks, 1, "HTML 4.01 Strict"},
+		{"html401_transitional.html", "HTML 4.01 Transitional", ModeLimitedQuirks, 1, "HTML 4.01 Transitional"},
+		{"html401_transitional_no_system.html", "HTML 4.01 Transitional", ModeQuirks, 1, "No system identifier"},
+		{"html401_frameset.html", "HTML 4.01 Frameset", ModeLimitedQuirks, 1, "HTML 4.01 Frameset"},
+		{"xhtml10_transitional.html", "XHTML 1.0 Transitional", ModeLimitedQuirks, 1, "XHTML 1.0 Transitional"},
+		{"xhtml10_frameset.html", "XHTML 1.0 Frameset", ModeLimitedQuirks, 1, "XHTML 1.0 Frameset"},
+		{"xhtml11.html", "XHTML 1.1", ModeNoQuirks, 1, "XHTML 1.1"},
+		{"xhtml11_no_system.html", "XHTML 1.1", ModeNoQuirks, 0.9, "XHTML 1.1 without system identifier"},
+		{"html32.html", "HTML 3.2", ModeQuirks, 1, "HTML 3.2"},
+		{"no_doctype.html", "unknown", ModeQuirks, 0, "No doctype"},
+		// The comment swallows the rest of the document, doctype included
+		{"unterminated_comment.html", "unknown", ModeQuirks, 0, ""},
+		// Browsers ignore a doctype after content, in a comment or in a script
+		{"late_doctype.html", "unknown", ModeQuirks, 0, "Late doctype"},
+		{"commented_doctype.html", "unknown", ModeQuirks, 0, "Doctype in comment"},
+		{"script_doctype.html", "unknown", ModeQuirks, 0, "Doctype in script"},
+	}
+	base, _ := url.Parse("https://example.com/")
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "doctype", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := DetectDoctype(data)
+			if got.Version != tt.version || got.Mode != tt.mode || math.Abs(got.Confidence-tt.confidence) > 1e-9 {
+				t.Errorf("DetectDoctype() = %+v, want {Version:%s Mode:%s Confidence:%v}", got, tt.version, tt.mode, tt.confidence)
+			}
+
+			analysis, err := Analyze(base, bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if analysis.Doctype != got {
+				t.Errorf("Analyze() doctype = %+v, want %+v", analysis.Doctype, got)
+			}
+			if analysis.Title != tt.title {
+				t.Errorf("Analyze() title = %q, want %q", analysis.Title, tt.title)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package crawler
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"webcrawler-backend/internal/models"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxPageForms is the number of forms listed per page
+	maxPageForms = 50
+	// maxFormFields is the number of fields listed per form
+	maxFormFields = 50
+	// maxFormButtons is the number of buttons listed per form, and maxButtonText the length of their text
+	maxFormButtons = 10
+	maxButtonText  = 100
+	// minFormScore is the score a class needs before a form is given it
+	minFormScore = 3
+)
+
+// Form is a <form> found on a page
+type Form struct {
+	Class   string      `json:"class"` // See models.FormClasses, or other
+	Action  string      `json:"action,omitempty"`
+	Method  string      `json:"method"`
+	ID      string      `json:"id,omitempty"`
+	Fields  []FormField `json:"fields"`
+	Buttons []string    `json:"buttons,omitempty"`
+}
+
+// FormField is an input, select or textarea of a form
+type FormField struct {
+	Type         string `json:"type"`
+	Name         string `json:"name,omitempty"`
+	Autocomplete string `json:"autocomplete,omitempty"`
+}
+
+// formState collects what a <form> contains while the page is walked
+type formState struct {
+	form     Form
+	search   bool     // role="search" on the form
+	password bool     // The form has a password field
+	text     []string // Names, ids, placeholders, labels and the form's own attributes
+}
+
+func newFormState(base *url.URL, n *html.Node) *formState {
+	f := &formState{form: Form{
+		Method: strings.ToUpper(strings.TrimSpace(attr(n, "method"))),
+		ID:     attr(n, "id"),
+		Fields: []FormField{},
+	}}
+	if f.form.Method == "" {
+		f.form.Method = "GET"
+	}
+	if action := strings.TrimSpace(attr(n, "action")); action != "" {
+		if u, err := base.Parse(action); err == nil {
+			f.form.Action = u.String()
+		}
+	}
+	f.search = strings.EqualFold(attr(n, "role"), "search")
+	f.text = append(f.text, attr(n, "action"), attr(n, "id"), attr(n, "name"), attr(n, "class"), attr(n, "aria-label"))
+	return f
+}
+
+// addField records an input, select or textarea
+func (f *formState) addField(n *html.Node) {
+	fieldType := n.Data
+	if n.Data == "input" {
+		fieldType = strings.ToLower(strings.TrimSpace(attr(n, "type")))
+		if fieldType == "" {
+			fieldType = "text"
+		}
+	}
+	switch fieldType {
+	case "submit", "button", "image", "reset":
+		if value := strings.TrimSpace(attr(n, "value")); value != "" {
+			f.addButton(value)
+		} else if fieldType != "reset" {
+			f.addButton(fieldType)
+		}
+		return
+	case "password":
+		f.password = true
+	}
+	if len(f.form.Fields) < maxFormFields {
+		f.form.Fields = append(f.form.Fields, FormField{
+			Type:         fieldType,
+			Name:         attr(n, "name"),
+			Autocomplete: strings.ToLower(strings.TrimSpace(attr(n, "autocomplete"))),
+		})
+	}
+	f.text = append(f.text, attr(n, "name"), attr(n, "id"), attr(n, "placeholder"), attr(n, "aria-label"))
+}
+
+// addButton records the text of a button
+func (f *formState) addButton(text string) {
+	if len(f.form.Buttons) < maxFormButtons {
+		f.form.Buttons = append(f.form.Buttons, truncate(text, maxButtonText))
+	}
+}
+
+// Patterns matched against the words of a form
+var (
+	loginWords    = regexp.MustCompile(`\b(log ?in|sign ?in|signin|login|logon|authenticate|session)\b`)
+	signupWords   = regexp.MustCompile(`\b(sign ?up|signup|register|registration|create (an )?account|join( now)?|get started)\b`)
+	resetWords    = regexp.MustCompile(`\b(forgot|forgotten|reset|recover|recovery|lost password)\b`)
+	searchWords   = regexp.MustCompile(`\b(search|find|query|keywords?)\b`)
+	newsWords     = regexp.MustCompile(`\b(subscribe|subscription|newsletter|mailing list|updates)\b`)
+	paymentWords  = regexp.MustCompile(`\b(pay|payment|checkout|place order|purchase|card ?number|cc ?num(ber)?|cvv|cvc|csc|expiry|expiration|iban|billing)\b`)
+	contactWords  = regexp.MustCompile(`\b(contact|message|enquiry|inquiry|comments?|feedback|subject|send)\b`)
+	confirmWords  = regexp.MustCompile(`\b(confirm|repeat|again|verify|retype)\b`)
+	nameWords     = regexp.MustCompile(`\b(first ?name|last ?name|full ?name|given ?name|family ?name|fname|lname)\b`)
+	wordSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// words lowercases text and separates its words with spaces, so that "user_name",
+// "userName" and "user-name" all contain "user name"
+func words(parts ...string) string {
+	var b strings.Builder
+	for _, part := range parts {
+		var prev rune
+		for _, r := range part {
+			if 'A' <= r && r <= 'Z' && 'a' <= prev && prev <= 'z' {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			prev = r
+		}
+		b.WriteByte(' ')
+	}
+	return " " + strings.TrimSpace(wordSeparator.ReplaceAllString(strings.ToLower(b.String()), " ")) + " "
+}
+
+// classifyForm gives a form the class with the highest score from its field types,
+// names, autocomplete attributes and button text, or other when nothing stands out
+func classifyForm(form Form, context, buttons string, search bool) string {
+	var (
+		passwords, newPasswords, currentPasswords int
+		emails, texts, textareas, visible         int
+		cardFields, searchInputs, queryNames      int
+	)
+	for _, field := range form.Fields {
+		switch field.Type {
+		case "hidden":
+			continue
+		case "password":
+			passwords++
+		case "email":
+			emails++
+		case "search":
+			searchInputs++
+		case "textarea":
+			textareas++
+		case "text", "tel":
+			texts++
+		}
+		visible++
+		// "q" and "s" only mean a query as a field name; as words they are too common
+		if name := strings.ToLower(field.Name); name == "q" || name == "s" {
+			queryNames++
+		}
+		switch {
+		case field.Autocomplete == "new-password":
+			newPasswords++
+		case field.Autocomplete == "current-password":
+			currentPasswords++
+		case strings.HasPrefix(field.Autocomplete, "cc-"):
+			cardFields++
+		case field.Autocomplete == "email" && field.Type != "email":
+			emails++
+		}
+	}
+
+	scores := make(map[string]int)
+	add := func(class string, points int) { scores[class] += points }
+
+	// Payment: card fields are decisive
+	add(models.FormPayment, 4*cardFields)
+	if paymentWords.MatchString(context) {
+		add(models.FormPayment, 2)
+	}
+	if paymentWords.MatchString(buttons) {
+		add(models.FormPayment, 2)
+	}
+
+	// Login: one password, usually with a user name or email
+	if passwords == 1 {
+		add(models.FormLogin, 3)
+		if newPasswords == 0 {
+			add(models.FormLogin, 1)
+		}
+	}
+	add(models.FormLogin, 2*currentPasswords)
+	if loginWords.MatchString(buttons) {
+		add(models.FormLogin, 3)
+	} else if loginWords.MatchString(context) {
+		add(models.FormLogin, 1)
+	}
+
+	// Signup: a new password (often confirmed), names, a signup button
+	if passwords >= 2 {
+		add(models.FormSignup, 3)
+	}
+	if newPasswords > 0 {
+		add(models.FormSignup, 2)
+	}
+	if confirmWords.MatchString(context) && passwords > 0 {
+		add(models.FormSignup, 1)
+	}
+	if nameWords.MatchString(context) {
+		add(models.FormSignup, 1)
+	}
+	if signupWords.MatchString(buttons) {
+		add(models.FormSignup, 4)
+	} else if signupWords.MatchString(context) {
+		add(models.FormSignup, 2)
+	}
+
+	// Password reset: an email or user name and reset wording, or only new passwords
+	if resetWords.MatchString(context + buttons) {
+		if passwords == 0 && visible <= 2 {
+			add(models.FormPasswordReset, 5)
+		} else if newPasswords > 0 && currentPasswords == 0 {
+			add(models.FormPasswordReset, 4)
+		} else {
+			add(models.FormPasswordReset, 1)
+		}
+	}
+
+	// Search: a search input or role, or a single query field submitted with GET
+	add(models.FormSearch, 4*searchInputs)
+	if search {
+		add(models.FormSearch, 4)
+	}
+	if passwords == 0 && visible == 1 && texts+searchInputs == 1 {
+		if form.Method == "GET" {
+			add(models.FormSearch, 1)
+		}
+		if searchWords.MatchString(context) || queryNames == 1 {
+			add(models.FormSearch, 2)
+		}
+	}
+	if searchWords.MatchString(buttons) {
+		add(models.FormSearch, 2)
+	}
+
+	// Newsletter: an email address and little else, with subscribe wording
+	if emails == 1 && passwords == 0 && textareas == 0 && visible <= 3 {
+		add(models.FormNewsletter, 1)
+		if newsWords.MatchString(context + buttons) {
+			add(models.FormNewsletter, 4)
+		} else if signupWords.MatchString(buttons) {
+			add(models.FormNewsletter, 2) // "Sign up" for updates, not an account
+		}
+	}
+
+	// Contact: a message box with the sender's details
+	if textareas > 0 && passwords == 0 {
+		add(models.FormContact, 2)
+		if emails > 0 || texts > 0 {
+			add(models.FormContact, 1)
+		}
+	}
+	if contactWords.MatchString(context + buttons) {
+		add(models.FormContact, 2)
+	}
+
+	// Ties go to the most specific class
+	class, best := models.FormOther, minFormScore-1
+	for _, candidate := range []string{models.FormPayment, models.FormPasswordReset, models.FormSignup, models.FormLogin, models.FormNewsletter, models.FormSearch, models.FormContact} {
+		if scores[candidate] > best {
+			class, best = candidate, scores[candidate]
+		}
+	}
+	return class
+}
+
+// finish classifies the form once all its content has been seen
+func (f *formState) finish() Form {
+	var autocomplete []string
+	for _, field := range f.form.Fields {
+		autocomplete = append(autocomplete, field.Autocomplete)
+	}
+	context := words(append(f.text, autocomplete...)...)
+	buttons := words(f.form.Buttons...)
+	f.form.Class = classifyForm(f.form, context, buttons, f.search)
+	return f.form
+}
+
+// textContent returns the text of a node and its descendants
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,156 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"webcrawler-backend/internal/models"
+)
+
+// analyzeHTML analyzes a page served from https://example.com/
+func analyzeHTML(t *testing.T, page string) *PageAnalysis {
+	t.Helper()
+	base, _ := url.Parse("https://example.com/")
+	analysis, err := Analyze(base, strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	return analysis
+}
+
+func TestClassifyForm(t *testing.T) {
+	tests := []struct {
+		name  string
+		form  string
+		class string
+		login bool // HasLoginForm: a password field
+	}{
+		{
+			"login",
+			`<form method="post" action="/session"><input type="email" name="email"><input type="password" name="password"><button>Log in</button></form>`,
+			models.FormLogin, true,
+		},
+		{
+			"login without wording",
+			`<form method="post"><input name="user"><input type="password" name="pass" autocomplete="current-password"><input type="submit" value="Go"></form>`,
+			models.FormLogin, true,
+		},
+		{
+			"signup",
+			`<form method="post"><input name="first_name"><input type="email" name="email"><input type="password" name="password" autocomplete="new-password"><input type="password" name="password_confirm"><button>Create account</button></form>`,
+			models.FormSignup, true,
+		},
+		{
+			"password reset",
+			`<form method="post" action="/forgot-password"><input type="email" name="email"><button>Reset password</button></form>`,
+			models.FormPasswordReset, false,
+		},
+		{
+			"search input",
+			`<form action="/search"><input type="search" name="query"></form>`,
+			models.FormSearch, false,
+		},
+		{
+			"search role",
+			`<form role="search"><input name="term"></form>`,
+			models.FormSearch, false,
+		},
+		{
+			"query field named q",
+			`<form><input name="q"></form>`,
+			models.FormSearch, false,
+		},
+		{
+			"query field named s",
+			`<form method="get"><input type="text" name="s"></form>`,
+			models.FormSearch, false,
+		},
+		{
+			// "s" in "Let's" is not a query field
+			"s as a word",
+			`<form method="post"><input type="email" name="email" placeholder="Let's talk"><button>Go</button></form>`,
+			models.FormOther, false,
+		},
+		{
+			"newsletter",
+			`<form method="post"><input type="email" name="email"><button>Subscribe to our newsletter</button></form>`,
+			models.FormNewsletter, false,
+		},
+		{
+			"payment",
+			`<form method="post"><input name="cc" autocomplete="cc-number"><input name="exp" autocomplete="cc-exp"><input name="cvc" autocomplete="cc-csc"><button>Pay</button></form>`,
+			models.FormPayment, false,
+		},
+		{
+			"contact",
+			`<form method="post"><input name="name"><input type="email" name="email"><textarea name="message"></textarea><button>Send</button></form>`,
+			models.FormContact, false,
+		},
+		{
+			"other",
+			`<form method="post"><select name="language"><option>en</option></select><input type="number" name="quantity"><button>Update</button></form>`,
+			models.FormOther, false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := analyzeHTML(t, "<html><body>"+tt.form+"</body></html>")
+			if len(analysis.Forms) != 1 {
+				t.Fatalf("found %d forms, want 1", len(analysis.Forms))
+			}
+			if got := analysis.Forms[0].Class; got != tt.class {
+				t.Errorf("class = %s, want %s", got, tt.class)
+			}
+			if analysis.HasLoginForm != tt.login {
+				t.Errorf("HasLoginForm = %v, want %v", analysis.HasLoginForm, tt.login)
+			}
+		})
+	}
+}
+
+func TestFormLimits(t *testing.T) {
+	var page strings.Builder
+	page.WriteString("<html><body>")
+	// One form more than listed; the last one has the only password field
+	for i := 0; i < maxPageForms; i++ {
+		fmt.Fprintf(&page, `<form><input name="field%d"></form>`, i)
+	}
+	page.WriteString(`<form method="post"><input name="user"><input type="password" name="pass"></form>`)
+	page.WriteString("</body></html>")
+
+	analysis := analyzeHTML(t, page.String())
+	if len(analysis.Forms) != maxPageForms {
+		t.Errorf("listed %d forms, want %d", len(analysis.Forms), maxPageForms)
+	}
+	if !analysis.HasLoginForm {
+		t.Error("HasLoginForm = false, want true for a password field in an unlisted form")
+	}
+
+	var form strings.Builder
+	form.WriteString("<form>")
+	for i := 0; i <= maxFormFields; i++ {
+		fmt.Fprintf(&form, `<input name="field%d">`, i)
+	}
+	for i := 0; i <= maxFormButtons; i++ {
+		fmt.Fprintf(&form, `<button>%s</button>`, strings.Repeat("b", maxButtonText+i))
+	}
+	form.WriteString("</form>")
+
+	analysis = analyzeHTML(t, "<html><body>"+form.String()+"</body></html>")
+	if len(analysis.Forms) != 1 {
+		t.Fatalf("found %d forms, want 1", len(analysis.Forms))
+	}
+	got := analysis.Forms[0]
+	if len(got.Fields) != maxFormFields {
+		t.Errorf("listed %d fields, want %d", len(got.Fields), maxFormFields)
+	}
+	if len(got.Buttons) != maxFormButtons {
+		t.Errorf("listed %d buttons, want %d", len(got.Buttons), maxFormButtons)
+	}
+	for i, button := range got.Buttons {
+		if len(button) != maxButtonText {
+			t.Errorf("button %d has %d characters, want %d", i, len(button), maxButtonText)
+		}
+	}
+}
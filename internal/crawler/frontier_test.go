@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// BenchmarkMemoryFrontier adds and visits b.N distinct URLs of growing length. The visited
+// set reported as seen-B/url stays the same whatever the URL length, and the heap retained
+// once every URL is visited (retained-B/url) does not grow with the number of URLs.
+func BenchmarkMemoryFrontier(b *testing.B) {
+	for _, length := range []int{64, 512, 2048} {
+		b.Run(fmt.Sprintf("url_length=%d", length), func(b *testing.B) {
+			path := strings.Repeat("a", length-len("https://example.com/?id=0000000000"))
+			f := NewMemoryFrontier(b.N, 0.01)
+
+			var before runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				url := fmt.Sprintf("https://example.com/%s?id=%010d", path, i)
+				if _, err := f.Add([]FrontierEntry{{URL: url}, {URL: url}}); err != nil {
+					b.Fatal(err)
+				}
+				next, _ := f.Next()
+				f.Done(next)
+			}
+			b.StopTimer()
+
+			var after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+			b.ReportMetric(float64(f.SeenSizeBytes())/float64(b.N), "seen-B/url")
+			b.ReportMetric(float64(max(retained, 0))/float64(b.N), "retained-B/url")
+			if pending, _ := f.Pending(); pending != 0 {
+				b.Fatalf("%d URLs still pending, duplicates were queued", pending)
+			}
+		})
+	}
+}
+
+// BenchmarkLinkSet adds b.N distinct links of growing length; the memory per link does not
+// depend on the link's length.
+func BenchmarkLinkSet(b *testing.B) {
+	for _, length := range []int{64, 2048} {
+		b.Run(fmt.Sprintf("url_length=%d", length), func(b *testing.B) {
+			path := strings.Repeat("a", length-len("https://example.com/?id=0000000000"))
+			links := newLinkSet(b.N)
+
+			var before runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !links.add(fmt.Sprintf("https://example.com/%s?id=%010d", path, i)) {
+					b.Fatalf("link %d reported as already checked", i)
+				}
+			}
+			b.StopTimer()
+
+			var after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+			b.ReportMetric(float64(max(retained, 0))/float64(b.N), "retained-B/url")
+			runtime.KeepAlive(links)
+		})
+	}
+}
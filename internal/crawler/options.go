@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Limits of the per-crawl options
+const (
+	defaultTimeoutSeconds = 15
+	maxTimeoutSeconds     = 120
+	defaultMaxDepth       = 5
+	maxMaxDepth           = 50
+	maxCustomHeaders      = 20
+	maxUserAgentLength    = 255
+)
+
+// forbiddenHeaders are set by the crawler itself and cannot be overridden
+var forbiddenHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Transfer-Encoding": true, "Connection": true,
+	"Upgrade": true, "Te": true, "Trailer": true, "Keep-Alive": true, "Proxy-Authorization": true,
+}
+
+// credentialHeaders carry credentials, which are stored encrypted with the crawl's auth
+var credentialHeaders = map[string]bool{"Authorization": true, "Cookie": true}
+
+// Options configure how a crawl fetches pages. Unset fields take the defaults,
+// and the stored options always have every field set.
+type Options struct {
+	UserAgent          string            `json:"user_agent"`
+	TimeoutSeconds     int               `json:"timeout_seconds"` // Per request
+	MaxDepth           *int              `json:"max_depth"`       // Link hops from the seed URL
+	MaxPages           int               `json:"max_pages"`       // Same as the crawl's max_pages
+	FollowRedirects    *bool             `json:"follow_redirects"`
+	CheckExternalLinks *bool             `json:"check_external_links"`
+	Headers            map[string]string `json:"headers,omitempty"`
+	RespectRobots      *bool             `json:"respect_robots"`
+}
+
+// DefaultOptions returns the options of crawls created without any
+func DefaultOptions() Options {
+	o, _ := Options{}.Normalize()
+	return o
+}
+
+// Normalize validates the options and fills unset fields with the defaults
+func (o Options) Normalize() (Options, error) {
+	o.UserAgent = strings.TrimSpace(o.UserAgent)
+	if o.UserAgent == "" {
+		o.UserAgent = userAgent
+	}
+	if len(o.UserAgent) > maxUserAgentLength || strings.ContainsAny(o.UserAgent, "\r\n") {
+		return o, fmt.Errorf("user_agent must be a single line of at most %d characters", maxUserAgentLength)
+	}
+
+	if o.TimeoutSeconds == 0 {
+		o.TimeoutSeconds = defaultTimeoutSeconds
+	}
+	if o.TimeoutSeconds < 1 || o.TimeoutSeconds > maxTimeoutSeconds {
+		return o, fmt.Errorf("timeout_seconds must be between 1 and %d", maxTimeoutSeconds)
+	}
+
+	if o.MaxDepth == nil {
+		o.MaxDepth = intPtr(defaultMaxDepth)
+	}
+	if *o.MaxDepth < 0 || *o.MaxDepth > maxMaxDepth {
+		return o, fmt.Errorf("max_depth must be between 0 and %d", maxMaxDepth)
+	}
+	if o.MaxPages < 0 {
+		return o, fmt.Errorf("max_pages cannot be negative")
+	}
+
+	if o.FollowRedirects == nil {
+		o.FollowRedirects = boolPtr(true)
+	}
+	if o.CheckExternalLinks == nil {
+		o.CheckExternalLinks = boolPtr(true)
+	}
+	if o.RespectRobots == nil {
+		o.RespectRobots = boolPtr(true)
+	}
+
+	if len(o.Headers) > maxCustomHeaders {
+		return o, fmt.Errorf("at most %d custom headers are allowed", maxCustomHeaders)
+	}
+	headers := make(map[string]string, len(o.Headers))
+	for name, value := range o.Headers {
+		canonical := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		if !validHeaderName(canonical) {
+			return o, fmt.Errorf("invalid header name %q", name)
+		}
+		if canonical == "User-Agent" {
+			return o, fmt.Errorf("use user_agent to set the User-Agent header")
+		}
+		if credentialHeaders[canonical] {
+			return o, fmt.Errorf("header %s cannot be set, use auth to send credentials", canonical)
+		}
+		if forbiddenHeaders[canonical] {
+			return o, fmt.Errorf("header %s cannot be set", canonical)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return o, fmt.Errorf("header %s must be a single line", canonical)
+		}
+		headers[canonical] = value
+	}
+	if len(headers) > 0 {
+		o.Headers = headers
+	} else {
+		o.Headers = nil
+	}
+	return o, nil
+}
+
+// timeout returns the per-request timeout
+func (o Options) timeout() time.Duration {
+	return time.Duration(o.TimeoutSeconds) * time.Second
+}
+
+// apply sets the user agent on a request, and the custom headers when it goes to the crawled site
+// without downgrading to plain http
+func (o Options) apply(req *http.Request, seed *url.URL) {
+	if credentialTarget(seed, req.URL) {
+		for name, value := range o.Headers {
+			req.Header.Set(name, value)
+		}
+	}
+	req.Header.Set("User-Agent", o.UserAgent)
+}
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, ch := range name {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", ch)) {
+			return false
+		}
+	}
+	return true
+}
+
+func intPtr(v int) *int    { return &v }
+func boolPtr(v bool) *bool { return &v }
+
+// Merge returns the options with the fields set in override replacing them. Headers are merged by name.
+func (o Options) Merge(override Options) Options {
+	if override.UserAgent != "" {
+		o.UserAgent = override.UserAgent
+	}
+	if override.TimeoutSeconds != 0 {
+		o.TimeoutSeconds = override.TimeoutSeconds
+	}
+	if override.MaxDepth != nil {
+		o.MaxDepth = override.MaxDepth
+	}
+	if override.MaxPages != 0 {
+		o.MaxPages = override.MaxPages
+	}
+	if override.FollowRedirects != nil {
+		o.FollowRedirects = override.FollowRedirects
+	}
+	if override.CheckExternalLinks != nil {
+		o.CheckExternalLinks = override.CheckExternalLinks
+	}
+	if override.RespectRobots != nil {
+		o.RespectRobots = override.RespectRobots
+	}
+	if len(override.Headers) > 0 {
+		headers := make(map[string]string, len(o.Headers)+len(override.Headers))
+		for name, value := range o.Headers {
+			headers[name] = value
+		}
+		for name, value := range override.Headers {
+			headers[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))] = value
+		}
+		o.Headers = headers
+	}
+	return o
+}
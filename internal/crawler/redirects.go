@@ -0,0 +1,218 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"webcrawler-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxRedirects is the number of redirects followed before giving up
+	maxRedirects = 10
+	// longRedirectChain is the number of redirects above which a chain is reported as too long
+	longRedirectChain = 3
+)
+
+// errTooManyRedirects stops a request that keeps being redirected
+var errTooManyRedirects = fmt.Errorf("stopped after %d redirects", maxRedirects)
+
+// RedirectHop is one response of a redirect chain
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// redirectTrace collects the responses received for one request, redirects included
+type redirectTrace struct {
+	mu   sync.Mutex
+	hops []RedirectHop
+}
+
+type traceKey struct{}
+
+// withRedirectTrace returns a context whose requests are recorded in the returned trace
+func withRedirectTrace(ctx context.Context) (context.Context, *redirectTrace) {
+	trace := &redirectTrace{}
+	return context.WithValue(ctx, traceKey{}, trace), trace
+}
+
+// tracingTransport records every response to a traced request
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace, _ := req.Context().Value(traceKey{}).(*redirectTrace)
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if trace != nil && err == nil {
+		trace.mu.Lock()
+		trace.hops = append(trace.hops, RedirectHop{
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Location:   resp.Header.Get("Location"),
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+		trace.mu.Unlock()
+	}
+	return resp, err
+}
+
+// checkRedirect limits the length of redirect chains. A URL may come back (e.g. after a
+// cookie is set), so loops are only recognized once the limit is reached.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errTooManyRedirects
+	}
+	return nil
+}
+
+// redirectChain returns the redirects of a traced request and the issues found in them,
+// or nil when the request was not redirected
+func redirectChain(source, requested string, trace *redirectTrace, err error) *models.RedirectChain {
+	trace.mu.Lock()
+	hops := append([]RedirectHop(nil), trace.hops...)
+	trace.mu.Unlock()
+
+	redirects := 0
+	for _, hop := range hops {
+		if isRedirect(hop.StatusCode) {
+			redirects++
+		}
+	}
+	if redirects == 0 {
+		return nil
+	}
+
+	last := hops[len(hops)-1]
+	chain := &models.RedirectChain{
+		Source:   source,
+		URL:      requested,
+		FinalURL: last.URL,
+		HopCount: redirects,
+	}
+	if err == nil {
+		chain.FinalStatus = last.StatusCode
+		if isRedirect(last.StatusCode) {
+			// Not followed (follow_redirects is off)
+			if target := hopTarget(last); target != nil {
+				chain.FinalURL = target.String()
+			}
+		}
+	}
+	chain.Hops, _ = json.Marshal(hops)
+	chain.Issues = redirectIssues(requested, hops, err)
+	return chain
+}
+
+// redirectIssues reports loops, long chains, HTTPS to HTTP downgrades and temporary
+// redirects to what looks like the canonical URL
+func redirectIssues(requested string, hops []RedirectHop, err error) []models.CrawlIssue {
+	var issues []models.CrawlIssue
+	issue := func(issueType, detail string) {
+		issues = append(issues, models.CrawlIssue{Type: issueType, URL: requested, Detail: detail})
+	}
+
+	seen := make(map[string]bool)
+	repeated := ""
+	redirects := 0
+	for _, hop := range hops {
+		if seen[hop.URL] && repeated == "" {
+			repeated = hop.URL
+		}
+		seen[hop.URL] = true
+		if !isRedirect(hop.StatusCode) {
+			continue
+		}
+		redirects++
+
+		target := hopTarget(hop)
+		if target == nil {
+			continue
+		}
+		from, _ := url.Parse(hop.URL)
+		if from.Scheme == "https" && target.Scheme == "http" {
+			issue(models.IssueHTTPSDowngrade, fmt.Sprintf("%s redirects to %s", hop.URL, target))
+		}
+		if (hop.StatusCode == http.StatusFound || hop.StatusCode == http.StatusTemporaryRedirect) && canonicalRedirect(from, target) {
+			issue(models.IssueTemporaryRedirect, fmt.Sprintf("%s redirects to %s with %d, use 301 or 308", hop.URL, target, hop.StatusCode))
+		}
+	}
+
+	switch {
+	case errors.Is(err, errTooManyRedirects) && repeated != "":
+		issue(models.IssueRedirectLoop, fmt.Sprintf("Redirects keep coming back to %s", repeated))
+	case errors.Is(err, errTooManyRedirects) || redirects > longRedirectChain:
+		issue(models.IssueLongRedirectChain, fmt.Sprintf("%d redirects before the final URL, at most %d recommended", redirects, longRedirectChain))
+	}
+	return issues
+}
+
+// canonicalRedirect reports whether a redirect only changes the scheme, a leading "www.",
+// the case of the host or a trailing slash. Such redirects are meant to be permanent.
+func canonicalRedirect(from, to *url.URL) bool {
+	if from.Scheme == to.Scheme && from.Host == to.Host && from.Path == to.Path {
+		return false
+	}
+	fromHost := strings.TrimPrefix(strings.ToLower(from.Host), "www.")
+	toHost := strings.TrimPrefix(strings.ToLower(to.Host), "www.")
+	return fromHost == toHost &&
+		strings.TrimSuffix(from.Path, "/") == strings.TrimSuffix(to.Path, "/") &&
+		from.RawQuery == to.RawQuery
+}
+
+// hopTarget resolves the Location of a redirect, or returns nil
+func hopTarget(hop RedirectHop) *url.URL {
+	from, err := url.Parse(hop.URL)
+	if err != nil || hop.Location == "" {
+		return nil
+	}
+	target, err := from.Parse(hop.Location)
+	if err != nil {
+		return nil
+	}
+	return target
+}
+
+func isRedirect(status int) bool {
+	return status >= 300 && status < 400 && status != http.StatusNotModified
+}
+
+// storeRedirects stores redirect chains with their issues and adds the issues to counts.
+// A resumed crawl may fetch or check a URL again; keep one chain per URL.
+func storeRedirects(tx *gorm.DB, crawlID uint, chains []*models.RedirectChain, counts map[string]int) error {
+	for _, chain := range chains {
+		if len(chain.URL) > 2048 || len(chain.FinalURL) > 2048 {
+			continue // Does not fit the redirect_chains table
+		}
+		var count int64
+		if err := tx.Model(&models.RedirectChain{}).Where("crawl_result_id = ? AND url = ?", crawlID, chain.URL).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		chain.CrawlResultID = crawlID
+		for i := range chain.Issues {
+			chain.Issues[i].CrawlResultID = crawlID
+		}
+		if err := tx.Create(chain).Error; err != nil {
+			return err
+		}
+		for _, issue := range chain.Issues {
+			counts[issue.Type]++
+		}
+	}
+	return nil
+}
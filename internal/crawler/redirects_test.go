@@ -0,0 +1,154 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"webcrawler-backend/internal/models"
+)
+
+// hops builds a chain of redirects through urls, ending with a 200 at the last one
+func hops(status int, urls ...string) []RedirectHop {
+	var chain []RedirectHop
+	for i, u := range urls {
+		if i == len(urls)-1 {
+			chain = append(chain, RedirectHop{URL: u, StatusCode: http.StatusOK})
+		} else {
+			chain = append(chain, RedirectHop{URL: u, StatusCode: status, Location: urls[i+1]})
+		}
+	}
+	return chain
+}
+
+func TestRedirectIssues(t *testing.T) {
+	var loop []string
+	for i := 0; i <= maxRedirects; i++ {
+		loop = append(loop, fmt.Sprintf("https://example.com/%c", 'a'+i%2))
+	}
+	var endless []string
+	for i := 0; i <= maxRedirects; i++ {
+		endless = append(endless, fmt.Sprintf("https://example.com/%d", i))
+	}
+	var long, limit []string
+	for i := 0; i <= longRedirectChain+1; i++ {
+		long = append(long, fmt.Sprintf("https://example.com/%d", i))
+	}
+	limit = long[:longRedirectChain+1]
+
+	tests := []struct {
+		name string
+		hops []RedirectHop
+		err  error
+		want []string // Issue types, in order
+	}{
+		{"no redirect", hops(0, "https://example.com/"), nil, nil},
+		{"permanent redirect", hops(http.StatusMovedPermanently, "https://example.com/old", "https://example.com/new"), nil, nil},
+		{"https to http", hops(http.StatusMovedPermanently, "https://example.com/", "http://example.com/"), nil, []string{models.IssueHTTPSDowngrade}},
+		{"relative location keeps https", []RedirectHop{
+			{URL: "https://example.com/a", StatusCode: http.StatusMovedPermanently, Location: "/b"},
+			{URL: "https://example.com/b", StatusCode: http.StatusOK},
+		}, nil, nil},
+		{"http to https", hops(http.StatusMovedPermanently, "http://example.com/", "https://example.com/"), nil, nil},
+		{"temporary redirect to https", hops(http.StatusFound, "http://example.com/", "https://example.com/"), nil, []string{models.IssueTemporaryRedirect}},
+		{"temporary redirect to www", hops(http.StatusTemporaryRedirect, "https://example.com/a", "https://www.example.com/a"), nil, []string{models.IssueTemporaryRedirect}},
+		{"temporary redirect to a trailing slash", hops(http.StatusFound, "https://example.com/a", "https://example.com/a/"), nil, []string{models.IssueTemporaryRedirect}},
+		{"temporary redirect elsewhere", hops(http.StatusFound, "https://example.com/a", "https://example.com/login"), nil, nil},
+		{"temporary downgrade", hops(http.StatusFound, "https://example.com/", "http://example.com/"), nil, []string{models.IssueHTTPSDowngrade, models.IssueTemporaryRedirect}},
+		{"chain at the limit", hops(http.StatusMovedPermanently, limit...), nil, nil},
+		{"long chain", hops(http.StatusMovedPermanently, long...), nil, []string{models.IssueLongRedirectChain}},
+		{"loop", hops(http.StatusFound, loop...)[:maxRedirects+1], errTooManyRedirects, []string{models.IssueRedirectLoop}},
+		{"redirect limit without loop", hops(http.StatusMovedPermanently, endless...)[:maxRedirects+1], errTooManyRedirects, []string{models.IssueLongRedirectChain}},
+		{"URL coming back once", hops(http.StatusFound, "https://example.com/a", "https://example.com/login", "https://example.com/a"), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range redirectIssues(tt.hops[0].URL, tt.hops, tt.err) {
+				got = append(got, issue.Type)
+				if issue.URL != tt.hops[0].URL {
+					t.Errorf("issue %s URL = %s, want the requested %s", issue.Type, issue.URL, tt.hops[0].URL)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("redirectIssues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRedirectChainHopLimit follows real redirects through the crawler's client
+func TestRedirectChainHopLimit(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/loop/", func(w http.ResponseWriter, r *http.Request) {
+		next := "/loop/a"
+		if r.URL.Path == "/loop/a" {
+			next = "/loop/b"
+		}
+		http.Redirect(w, r, next, http.StatusFound)
+	})
+	mux.HandleFunc("/chain/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Path[len("/chain/"):])
+		if n == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/chain/%d", n-1), http.StatusMovedPermanently)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &http.Client{Transport: &tracingTransport{base: http.DefaultTransport}, CheckRedirect: checkRedirect}
+	get := func(path string) *models.RedirectChain {
+		ctx, trace := withRedirectTrace(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return redirectChain(models.RedirectSourceLink, server.URL+path, trace, err)
+	}
+
+	tests := []struct {
+		path     string
+		hopCount int
+		status   int
+		issues   []string
+	}{
+		{"/chain/0", 0, 0, nil},
+		{"/chain/2", 2, http.StatusOK, nil},
+		{fmt.Sprintf("/chain/%d", longRedirectChain+1), longRedirectChain + 1, http.StatusOK, []string{models.IssueLongRedirectChain}},
+		{fmt.Sprintf("/chain/%d", maxRedirects-1), maxRedirects - 1, http.StatusOK, []string{models.IssueLongRedirectChain}},
+		// The request after the last allowed redirect is not sent
+		{fmt.Sprintf("/chain/%d", maxRedirects), maxRedirects, 0, []string{models.IssueLongRedirectChain}},
+		{fmt.Sprintf("/chain/%d", maxRedirects+5), maxRedirects, 0, []string{models.IssueLongRedirectChain}},
+		{"/loop/start", maxRedirects, 0, []string{models.IssueRedirectLoop}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			chain := get(tt.path)
+			if tt.hopCount == 0 {
+				if chain != nil {
+					t.Fatalf("redirectChain() = %+v, want nil without redirects", chain)
+				}
+				return
+			}
+			if chain == nil {
+				t.Fatal("redirectChain() = nil, want a chain")
+			}
+			if chain.HopCount != tt.hopCount || chain.FinalStatus != tt.status {
+				t.Errorf("hops = %d, final status = %d, want %d, %d", chain.HopCount, chain.FinalStatus, tt.hopCount, tt.status)
+			}
+			var got []string
+			for _, issue := range chain.Issues {
+				got = append(got, issue.Type)
+			}
+			if !slices.Equal(got, tt.issues) {
+				t.Errorf("issues = %v, want %v", got, tt.issues)
+			}
+		})
+	}
+}
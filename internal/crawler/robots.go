@@ -0,0 +1,181 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxRobotsSize is the largest robots.txt read, as in Google's implementation
+const maxRobotsSize = 500 << 10
+
+// robotsRules are the Allow and Disallow lines of the robots.txt group that applies to the crawler
+type robotsRules struct {
+	allow    []robotsRule
+	disallow []robotsRule
+}
+
+// robotsRule is a path rule; * matches any characters and a trailing $ anchors the end
+type robotsRule struct {
+	length int // Length of the rule as written, which decides between conflicting rules
+	re     *regexp.Regexp
+}
+
+func newRobotsRule(rule string) robotsRule {
+	anchored := strings.HasSuffix(rule, "$")
+	parts := strings.Split(strings.TrimSuffix(rule, "$"), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return robotsRule{length: len(rule), re: regexp.MustCompile(expr)}
+}
+
+// allows reports whether a path may be fetched: the longest matching rule wins, Allow on ties
+func (r *robotsRules) allows(path string) bool {
+	if r == nil {
+		return true
+	}
+	allowLen, disallowLen := -1, -1
+	for _, rule := range r.allow {
+		if rule.length > allowLen && rule.re.MatchString(path) {
+			allowLen = rule.length
+		}
+	}
+	for _, rule := range r.disallow {
+		if rule.length > disallowLen && rule.re.MatchString(path) {
+			disallowLen = rule.length
+		}
+	}
+	return disallowLen < 0 || allowLen >= disallowLen
+}
+
+// parseRobots extracts the rules for the crawler's product token, falling back to the * group
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	agent = strings.ToLower(agent)
+	var (
+		specific, wildcard *robotsRules
+		current            []*robotsRules
+		inAgents           bool
+	)
+
+	scanner := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil // A new group starts
+			}
+			inAgents = true
+			name := strings.ToLower(value)
+			switch {
+			case name == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				current = append(current, wildcard)
+			case name != "" && strings.HasPrefix(agent, name):
+				if specific == nil {
+					specific = &robotsRules{}
+				}
+				current = append(current, specific)
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" {
+				continue // "Disallow:" allows everything
+			}
+			rule := newRobotsRule(value)
+			for _, group := range current {
+				if key == "allow" {
+					group.allow = append(group.allow, rule)
+				} else {
+					group.disallow = append(group.disallow, rule)
+				}
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	if specific != nil {
+		return specific
+	}
+	return wildcard
+}
+
+// robotsCache fetches robots.txt once per host during a crawl
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*robotsRules
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{hosts: make(map[string]*robotsRules)}
+}
+
+// allowed reports whether the crawler may fetch u. Unreachable or missing robots.txt files allow everything.
+func (c *robotsCache) allowed(ctx context.Context, s *session, u *url.URL) bool {
+	host := u.Scheme + "://" + u.Host
+	c.mu.Lock()
+	rules, ok := c.hosts[host]
+	c.mu.Unlock()
+
+	if !ok {
+		rules = c.fetch(ctx, s, host)
+		c.mu.Lock()
+		c.hosts[host] = rules
+		c.mu.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allows(path)
+}
+
+func (c *robotsCache) fetch(ctx context.Context, s *session, host string) *robotsRules {
+	req, err := s.newRequest(ctx, http.MethodGet, host+"/robots.txt")
+	if err != nil {
+		return nil
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return parseRobots(resp.Body, productToken(s.options.UserAgent))
+}
+
+// productToken returns the name robots.txt groups are matched against, e.g. "WebCrawler"
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(userAgent, "/")
+	token, _, _ = strings.Cut(token, " ")
+	return token
+}
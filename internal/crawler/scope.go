@@ -0,0 +1,256 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Reasons a discovered URL is not followed
+const (
+	SkipHost       = "host"
+	SkipPathPrefix = "path_prefix"
+	SkipInclude    = "include"
+	SkipExclude    = "exclude"
+	SkipExtension  = "extension"
+	SkipRobots     = "robots" // Disallowed by robots.txt, counted when the page is reached
+)
+
+// maxScopePatterns bounds each list of a scope
+const maxScopePatterns = 50
+
+// Scope restricts which discovered URLs a site crawl follows. The zero value follows
+// every URL on the seed's host.
+type Scope struct {
+	// AllowedHosts replaces the seed's host; "*.example.com" also matches subdomains
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	// PathPrefix limits the crawl to paths under it, e.g. "/docs/"
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// Include patterns: when set, a URL path must match one of them
+	Include []string `json:"include,omitempty"`
+	// Exclude patterns: a URL path matching one of them is skipped
+	Exclude []string `json:"exclude,omitempty"`
+	// StripQueryParams are removed from URLs before they are queued; "*" removes the whole query
+	StripQueryParams []string `json:"strip_query_params,omitempty"`
+	// BlockedExtensions are file extensions never fetched, e.g. "pdf" or ".zip"
+	BlockedExtensions []string `json:"blocked_extensions,omitempty"`
+}
+
+// ScopeRules is a validated scope ready to filter URLs
+type ScopeRules struct {
+	hosts      []string
+	pathPrefix string
+	include    []*regexp.Regexp
+	exclude    []*regexp.Regexp
+	stripAll   bool
+	strip      map[string]bool
+	extensions map[string]bool
+}
+
+// Normalize validates a scope and returns it in canonical form (lowercase hosts and extensions)
+func (s Scope) Normalize() (Scope, error) {
+	for name, list := range map[string][]string{
+		"allowed_hosts": s.AllowedHosts, "include": s.Include, "exclude": s.Exclude,
+		"strip_query_params": s.StripQueryParams, "blocked_extensions": s.BlockedExtensions,
+	} {
+		if len(list) > maxScopePatterns {
+			return s, fmt.Errorf("%s accepts at most %d entries", name, maxScopePatterns)
+		}
+	}
+
+	hosts := make([]string, 0, len(s.AllowedHosts))
+	for _, h := range s.AllowedHosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		name := strings.TrimPrefix(h, "*.")
+		if name == "" || strings.ContainsAny(name, "/:*?#@ ") {
+			return s, fmt.Errorf("invalid allowed host %q", h)
+		}
+		hosts = append(hosts, h)
+	}
+	s.AllowedHosts = hosts
+
+	if s.PathPrefix != "" && !strings.HasPrefix(s.PathPrefix, "/") {
+		return s, fmt.Errorf("path_prefix must start with /")
+	}
+
+	for _, p := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := compilePattern(p); err != nil {
+			return s, err
+		}
+	}
+
+	extensions := make([]string, 0, len(s.BlockedExtensions))
+	for _, ext := range s.BlockedExtensions {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext == "" || strings.ContainsAny(ext, "/. ") {
+			return s, fmt.Errorf("invalid blocked extension %q", ext)
+		}
+		extensions = append(extensions, ext)
+	}
+	s.BlockedExtensions = extensions
+
+	for _, param := range s.StripQueryParams {
+		if strings.TrimSpace(param) == "" {
+			return s, fmt.Errorf("strip_query_params cannot contain empty names")
+		}
+	}
+	return s, nil
+}
+
+// Compile validates the scope for a crawl of seed
+func (s Scope) Compile(seed *url.URL) (*ScopeRules, error) {
+	s, err := s.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &ScopeRules{
+		hosts:      s.AllowedHosts,
+		pathPrefix: s.PathPrefix,
+		strip:      make(map[string]bool),
+		extensions: make(map[string]bool),
+	}
+	if len(rules.hosts) == 0 {
+		rules.hosts = []string{strings.TrimPrefix(strings.ToLower(seed.Hostname()), "www.")}
+	}
+	for _, p := range s.Include {
+		re, _ := compilePattern(p)
+		rules.include = append(rules.include, re)
+	}
+	for _, p := range s.Exclude {
+		re, _ := compilePattern(p)
+		rules.exclude = append(rules.exclude, re)
+	}
+	for _, param := range s.StripQueryParams {
+		if param == "*" {
+			rules.stripAll = true
+		}
+		rules.strip[param] = true
+	}
+	for _, ext := range s.BlockedExtensions {
+		rules.extensions[ext] = true
+	}
+	return rules, nil
+}
+
+// Apply returns the URL to queue, with stripped query parameters removed, or the reason it is skipped
+func (r *ScopeRules) Apply(u *url.URL) (*url.URL, string) {
+	if !r.allowsHost(u.Hostname()) {
+		return nil, SkipHost
+	}
+
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if r.pathPrefix != "" && !strings.HasPrefix(p, r.pathPrefix) {
+		return nil, SkipPathPrefix
+	}
+	if ext := strings.TrimPrefix(strings.ToLower(path.Ext(u.Path)), "."); ext != "" && r.extensions[ext] {
+		return nil, SkipExtension
+	}
+	if len(r.include) > 0 && !matchAny(r.include, p) {
+		return nil, SkipInclude
+	}
+	if matchAny(r.exclude, p) {
+		return nil, SkipExclude
+	}
+
+	if u.RawQuery == "" || (!r.stripAll && len(r.strip) == 0) {
+		return u, ""
+	}
+	stripped := *u
+	if r.stripAll {
+		stripped.RawQuery = ""
+		return &stripped, ""
+	}
+	query := u.Query()
+	for param := range r.strip {
+		query.Del(param)
+	}
+	stripped.RawQuery = query.Encode()
+	return &stripped, ""
+}
+
+func (r *ScopeRules) allowsHost(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, allowed := range r.hosts {
+		if wildcard := strings.TrimPrefix(allowed, "*."); wildcard != allowed {
+			if host == wildcard || strings.HasSuffix(host, "."+wildcard) {
+				return true
+			}
+		} else if host == strings.TrimPrefix(allowed, "www.") {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePattern compiles "re:<regexp>" patterns as regular expressions and anything else as a
+// glob over the URL path, where * matches within a path segment and ** across segments
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		return re, nil
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("patterns cannot be empty")
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge returns the scope with the fields set in override replacing them
+func (s Scope) Merge(override Scope) Scope {
+	if override.AllowedHosts != nil {
+		s.AllowedHosts = override.AllowedHosts
+	}
+	if override.PathPrefix != "" {
+		s.PathPrefix = override.PathPrefix
+	}
+	if override.Include != nil {
+		s.Include = override.Include
+	}
+	if override.Exclude != nil {
+		s.Exclude = override.Exclude
+	}
+	if override.StripQueryParams != nil {
+		s.StripQueryParams = override.StripQueryParams
+	}
+	if override.BlockedExtensions != nil {
+		s.BlockedExtensions = override.BlockedExtensions
+	}
+	return s
+}
@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/blog/*", "/blog/post", true},
+		{"/blog/*", "/blog/", true},
+		{"/blog/*", "/blog/2024/post", false},
+		{"/blog/**", "/blog/2024/post", true},
+		{"/blog/**", "/blogroll", false},
+		{"**.pdf", "/files/report.pdf", true},
+		{"*.pdf", "/files/report.pdf", false},
+		{"/page?", "/page1", true},
+		{"/page?", "/page/", false},
+		{"/page?", "/page10", false},
+		{"/a.b", "/a.b", true},
+		{"/a.b", "/axb", false}, // Dots are literal in globs
+		{"/(draft)", "/(draft)", true},
+		{"re:^/user/[0-9]+$", "/user/42", true},
+		{"re:^/user/[0-9]+$", "/user/me", false},
+		{"re:print", "/articles/print/1", true}, // Regular expressions are not anchored
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			re, err := compilePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("compilePattern() error = %v", err)
+			}
+			if got := re.MatchString(tt.path); got != tt.match {
+				t.Errorf("match = %v, want %v", got, tt.match)
+			}
+		})
+	}
+
+	for _, pattern := range []string{"", "re:(unclosed"} {
+		if _, err := compilePattern(pattern); err == nil {
+			t.Errorf("compilePattern(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestScopeApply(t *testing.T) {
+	seed, _ := url.Parse("https://www.example.com/")
+	tests := []struct {
+		name   string
+		scope  Scope
+		link   string
+		want   string // Queued URL, empty when skipped
+		reason string
+	}{
+		{"seed host", Scope{}, "https://example.com/a", "https://example.com/a", ""},
+		{"seed host with www", Scope{}, "https://WWW.Example.com/a", "https://WWW.Example.com/a", ""},
+		{"other host", Scope{}, "https://other.com/a", "", SkipHost},
+		{"subdomain of the seed", Scope{}, "https://blog.example.com/a", "", SkipHost},
+		{"allowed host", Scope{AllowedHosts: []string{"docs.example.com"}}, "https://docs.example.com/", "https://docs.example.com/", ""},
+		{"seed host not allowed", Scope{AllowedHosts: []string{"docs.example.com"}}, "https://example.com/", "", SkipHost},
+		{"wildcard subdomain", Scope{AllowedHosts: []string{"*.example.com"}}, "https://a.b.example.com/", "https://a.b.example.com/", ""},
+		{"wildcard apex", Scope{AllowedHosts: []string{"*.example.com"}}, "https://example.com/", "https://example.com/", ""},
+		{"wildcard lookalike", Scope{AllowedHosts: []string{"*.example.com"}}, "https://badexample.com/", "", SkipHost},
+		{"path prefix", Scope{PathPrefix: "/docs/"}, "https://example.com/docs/intro", "https://example.com/docs/intro", ""},
+		{"outside path prefix", Scope{PathPrefix: "/docs/"}, "https://example.com/blog", "", SkipPathPrefix},
+		{"empty path outside prefix", Scope{PathPrefix: "/docs/"}, "https://example.com", "", SkipPathPrefix},
+		{"blocked extension", Scope{BlockedExtensions: []string{".PDF"}}, "https://example.com/file.pdf", "", SkipExtension},
+		{"blocked extension case", Scope{BlockedExtensions: []string{"pdf"}}, "https://example.com/FILE.PDF", "", SkipExtension},
+		{"other extension", Scope{BlockedExtensions: []string{"pdf"}}, "https://example.com/page.html", "https://example.com/page.html", ""},
+		{"included", Scope{Include: []string{"/docs/**"}}, "https://example.com/docs/a/b", "https://example.com/docs/a/b", ""},
+		{"not included", Scope{Include: []string{"/docs/**"}}, "https://example.com/blog", "", SkipInclude},
+		{"excluded", Scope{Exclude: []string{"/admin/**"}}, "https://example.com/admin/users", "", SkipExclude},
+		{"exclude wins over include", Scope{Include: []string{"/**"}, Exclude: []string{"/private/*"}}, "https://example.com/private/a", "", SkipExclude},
+		{"escaped path matched", Scope{Exclude: []string{"/a%20b"}}, "https://example.com/a%20b", "", SkipExclude},
+		{"query parameter stripped", Scope{StripQueryParams: []string{"session"}}, "https://example.com/a?session=1&page=2", "https://example.com/a?page=2", ""},
+		{"whole query stripped", Scope{StripQueryParams: []string{"*"}}, "https://example.com/a?session=1&page=2", "https://example.com/a", ""},
+		{"query kept", Scope{}, "https://example.com/a?b=2&a=1", "https://example.com/a?b=2&a=1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := tt.scope.Compile(seed)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			link, _ := url.Parse(tt.link)
+			got, reason := rules.Apply(link)
+			if reason != tt.reason {
+				t.Fatalf("Apply() reason = %q, want %q", reason, tt.reason)
+			}
+			if got == nil {
+				if tt.want != "" {
+					t.Errorf("Apply() skipped the URL, want %s", tt.want)
+				}
+			} else if got.String() != tt.want {
+				t.Errorf("Apply() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeNormalize(t *testing.T) {
+	tooMany := make([]string, maxScopePatterns+1)
+	for i := range tooMany {
+		tooMany[i] = "/a"
+	}
+	invalid := []Scope{
+		{AllowedHosts: []string{"example.com/path"}},
+		{AllowedHosts: []string{"*."}},
+		{PathPrefix: "docs/"},
+		{Include: []string{"re:["}},
+		{Exclude: []string{""}},
+		{BlockedExtensions: []string{"tar.gz"}},
+		{StripQueryParams: []string{" "}},
+		{Exclude: tooMany},
+	}
+	for _, scope := range invalid {
+		if _, err := scope.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) succeeded, want an error", scope)
+		}
+	}
+
+	got, err := Scope{AllowedHosts: []string{" Docs.Example.com "}, BlockedExtensions: []string{".PDF"}}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AllowedHosts[0] != "docs.example.com" || got.BlockedExtensions[0] != "pdf" {
+		t.Errorf("Normalize() = %+v, want lowercase host and extension", got)
+	}
+}
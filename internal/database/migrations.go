@@ -4,6 +4,7 @@ import (
 	"log"
 	"runtime"
 	"fmt"
+	"strings"
 	"webcrawler-backend/internal/models"
 	"gorm.io/gorm"
 )
@@ -46,16 +47,42 @@ func RunMigrations(db *gorm.DB) error {
 	// Auto migrate all models
 	err := db.AutoMigrate(
 		&models.User{},
+		&models.Organization{},
+		&models.Membership{},
 		&models.RefreshToken{},
 		&models.APIKey{},
+		&models.PasswordResetToken{},
+		&models.LoginAttempt{},
+		&models.RecoveryCode{},
+		&models.OIDCLoginState{},
+		&models.SigningKey{},
 		&models.CrawlResult{},
+		&models.CrawlPreset{},
 		&models.BrokenLink{},
+		&models.CrawlPage{},
+		&models.FrontierURL{},
+		&models.RedirectChain{},
+		&models.CrawlIssue{},
+		&models.OutboxMessage{},
+		&models.AuditLog{},
+		&models.QuotaLimit{},
+		&models.NetworkAllowlistEntry{},
 	)
 	if err != nil {
 		logWithLevel("ERROR", "AutoMigrate failed: %v", err)
 		return err
 	}
 
+	// AutoMigrate does not notice new enum values, widen those columns explicitly
+	if err := migrateEnumColumn(db, &models.User{}, "Role"); err != nil {
+		logWithLevel("ERROR", "Failed to migrate users.role: %v", err)
+		return err
+	}
+	if err := migrateEnumColumn(db, &models.CrawlResult{}, "Status"); err != nil {
+		logWithLevel("ERROR", "Failed to migrate crawl_results.status: %v", err)
+		return err
+	}
+
 	logWithLevel("INFO", "Database migrations completed successfully!")
 	return nil
 }
@@ -70,7 +97,7 @@ func CreateTables(db *gorm.DB) error {
 			id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
 			url VARCHAR(500) NOT NULL,
 			title VARCHAR(500),
-			html_version VARCHAR(10),
+			html_version VARCHAR(30),
 			status VARCHAR(50) NOT NULL DEFAULT 'queued',
 			heading_counts JSON,
 			internal_links INT DEFAULT 0,
@@ -121,4 +148,34 @@ func CreateTables(db *gorm.DB) error {
 
 	logWithLevel("INFO", "Database tables created successfully")
 	return nil
-} 
\ No newline at end of file
+}
+
+// migrateEnumColumn alters an enum column when its values differ from the model's type tag.
+// GORM only compares the base type ("enum"), so added values would otherwise never reach the database.
+func migrateEnumColumn(db *gorm.DB, model interface{}, fieldName string) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	field := stmt.Schema.LookUpField(fieldName)
+	if field == nil {
+		return fmt.Errorf("unknown field %s", fieldName)
+	}
+
+	columnTypes, err := db.Migrator().ColumnTypes(model)
+	if err != nil {
+		return err
+	}
+	for _, columnType := range columnTypes {
+		if columnType.Name() != field.DBName {
+			continue
+		}
+		current, ok := columnType.ColumnType()
+		if ok && strings.EqualFold(current, string(field.DataType)) {
+			return nil
+		}
+		logWithLevel("INFO", "Altering %s.%s from %s to %s", stmt.Schema.Table, field.DBName, current, field.DataType)
+		return db.Migrator().AlterColumn(model, fieldName)
+	}
+	return nil
+}
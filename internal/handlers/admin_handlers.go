@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/middleware"
+	"webcrawler-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// impersonationTokenTTL is how long an impersonation session lasts; it cannot be refreshed
+const impersonationTokenTTL = 15 * time.Minute
+
+// AdminHandler handles admin user-management API requests
+type AdminHandler struct {
+	db             *gorm.DB
+	authMiddleware *middleware.AuthMiddleware
+	loginGuard     *middleware.LoginGuard
+	audit          *audit.Logger
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(db *gorm.DB, authMiddleware *middleware.AuthMiddleware, loginGuard *middleware.LoginGuard, auditLog *audit.Logger) *AdminHandler {
+	return &AdminHandler{
+		db:             db,
+		authMiddleware: authMiddleware,
+		loginGuard:     loginGuard,
+		audit:          auditLog,
+	}
+}
+
+// adminUserView adds computed lockout information to a user
+type adminUserView struct {
+	models.User
+	IsLocked bool `json:"is_locked"`
+}
+
+func newAdminUserView(user models.User) adminUserView {
+	return adminUserView{
+		User:     user,
+		IsLocked: user.LockedUntil != nil && user.LockedUntil.After(time.Now()),
+	}
+}
+
+// ListUsers returns users with their lockout state
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	email := c.Query("email")
+	locked := c.Query("locked")
+	limitInt, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offsetInt, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limitInt <= 0 || limitInt > 100 {
+		limitInt = 100
+	}
+
+	query := h.db.Model(&models.User{})
+	if email != "" {
+		query = query.Where("email LIKE ?", "%"+email+"%")
+	}
+	if locked == "true" {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+
+	var totalCount int64
+	if err := query.Count(&totalCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var users []models.User
+	if err := query.Order("created_at desc").Limit(limitInt).Offset(offsetInt).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	views := make([]adminUserView, 0, len(users))
+	for _, user := range users {
+		views = append(views, newAdminUserView(user))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": views,
+		"pagination": gin.H{
+			"total":    totalCount,
+			"limit":    limitInt,
+			"offset":   offsetInt,
+			"has_more": offsetInt+limitInt < int(totalCount),
+		},
+	})
+}
+
+// GetUser returns a user with lockout state and recent failed login attempts
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var attempts []models.LoginAttempt
+	if err := h.db.Where("user_id = ? AND success = ?", user.ID, false).
+		Order("created_at desc").Limit(20).Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":                  newAdminUserView(user),
+		"recent_login_failures": attempts,
+	})
+}
+
+// UnlockUser clears a temporary login lockout
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := h.loginGuard.Unlock(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionUserUnlocked,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Before:     gin.H{"locked_until": user.LockedUntil, "failed_login_count": user.FailedLoginCount},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// UpdateUserRole changes a user's global role
+func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid role (admin, operator, user, viewer) is required"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.ID == c.MustGet("user_id").(uint) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+	if user.Role == req.Role {
+		c.JSON(http.StatusOK, newAdminUserView(user))
+		return
+	}
+
+	previousRole := user.Role
+	if err := h.db.Model(&user).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionUserRoleChanged,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Before:     gin.H{"role": previousRole},
+		After:      gin.H{"role": req.Role},
+	})
+
+	c.JSON(http.StatusOK, newAdminUserView(user))
+}
+
+// ImpersonateUser issues a short-lived access token to act as the user for support.
+// Actions taken with it are attributed to the admin in the audit log, and sensitive
+// operations (password, MFA, API keys) are blocked.
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	admin := c.MustGet("user").(models.User)
+	if user.ID == admin.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot impersonate yourself"})
+		return
+	}
+	if user.Role == models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins cannot be impersonated"})
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User account is deactivated"})
+		return
+	}
+
+	token, err := h.authMiddleware.GenerateImpersonationToken(user, admin, impersonationTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionImpersonationStarted,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		After:      gin.H{"reason": req.Reason, "expires_in": int(impersonationTokenTTL.Seconds())},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"expires_in":   int(impersonationTokenTTL.Seconds()),
+		"user": gin.H{
+			"id":    user.ID,
+			"email": user.Email,
+			"name":  user.Name,
+			"role":  user.Role,
+		},
+	})
+}
@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/mailer"
 	"webcrawler-backend/internal/middleware"
 	"webcrawler-backend/internal/models"
 	"github.com/gin-gonic/gin"
@@ -14,13 +19,21 @@ import (
 type AuthHandler struct {
 	db            *gorm.DB
 	authMiddleware *middleware.AuthMiddleware
+	mailer         mailer.Mailer
+	appBaseURL     string // Frontend URL used to build links in emails
+	loginGuard     *middleware.LoginGuard
+	audit          *audit.Logger
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(db *gorm.DB, authMiddleware *middleware.AuthMiddleware) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, authMiddleware *middleware.AuthMiddleware, m mailer.Mailer, appBaseURL string, loginGuard *middleware.LoginGuard, auditLog *audit.Logger) *AuthHandler {
 	return &AuthHandler{
 		db:             db,
 		authMiddleware: authMiddleware,
+		mailer:         m,
+		appBaseURL:     appBaseURL,
+		loginGuard:     loginGuard,
+		audit:          auditLog,
 	}
 }
 
@@ -45,40 +58,98 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Throttle clients with too many recent failures
+	wait, err := h.loginGuard.CheckIP(c.ClientIP(), middleware.AttemptLogin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if wait > 0 {
+		h.recordLoginFailure(c, req.Email, nil, "throttled")
+		respondTooManyAttempts(c, wait, "Too many failed login attempts, please try again later")
+		return
+	}
+
 	// Find user by email
 	var user models.User
 	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		h.recordLoginFailure(c, req.Email, nil, "unknown_email")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	// Check for account lockout and progressive delay
+	if locked, wait := h.loginGuard.CheckAccount(user); locked {
+		h.recordLoginFailure(c, req.Email, &user, "locked")
+		respondTooManyAttempts(c, wait, "Account temporarily locked due to too many failed login attempts")
+		return
+	} else if wait > 0 {
+		h.recordLoginFailure(c, req.Email, &user, "throttled")
+		respondTooManyAttempts(c, wait, "Too many failed login attempts, please try again later")
+		return
+	}
+
 	// Check if user is active
 	if !user.IsActive {
+		h.recordLoginFailure(c, req.Email, &user, "deactivated")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
 		return
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		h.recordLoginFailure(c, req.Email, &user, "invalid_credentials")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err := h.authMiddleware.GenerateTokens(user)
+	// Second step required: the client must answer the MFA challenge before getting tokens
+	if user.MFAEnabled {
+		h.respondMFAChallenge(c, user)
+		return
+	}
+
+	h.completeLogin(c, user)
+}
+
+// completeLogin resets the failure counter, issues tokens and writes the login response
+func (h *AuthHandler) completeLogin(c *gin.Context, user models.User) {
+	response, err := h.issueLoginTokens(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
 
-	// Update last login
-	// h.db.Model(&user).Update("last_login", time.Now())
-if err := h.db.Model(&user).Update("last_login", time.Now()).Error; err != nil {
-    // Log the error but don't fail the login
- c.Set("last_login_update_error", err)
+	c.JSON(http.StatusOK, response)
 }
 
-	c.JSON(http.StatusOK, gin.H{
+// issueLoginTokens resets the failure counter, updates the last login and returns the login response body
+func (h *AuthHandler) issueLoginTokens(c *gin.Context, user models.User) (gin.H, error) {
+	if err := h.loginGuard.RecordSuccess(c, middleware.AttemptLogin, user); err != nil {
+		log.Printf("[WARN] Failed to reset login failures for user %d: %v", user.ID, err)
+	}
+
+	// Generate tokens
+	accessToken, refreshToken, err := h.authMiddleware.GenerateTokens(user)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update last login
+	if err := h.db.Model(&user).Update("last_login", time.Now()).Error; err != nil {
+		// Log the error but don't fail the login
+		c.Set("last_login_update_error", err)
+	}
+
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionLogin,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		After:      gin.H{"auth_provider": user.AuthProvider, "mfa": user.MFAEnabled},
+		Actor:      &user,
+	})
+
+	return gin.H{
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
 		"user": gin.H{
@@ -86,8 +157,11 @@ if err := h.db.Model(&user).Update("last_login", time.Now()).Error; err != nil {
 			"email": user.Email,
 			"name":  user.Name,
 			"role":  user.Role,
+			"email_verified": user.EmailVerified,
 		},
-	})
+		// Users whose role requires MFA must enroll before using the rest of the API
+		"mfa_enrollment_required": !user.MFAEnabled && h.authMiddleware.MFARequiredForRole(user.Role),
+	}, nil
 }
 
 // Register handles user registration
@@ -98,9 +172,23 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// Limit registrations per client
+	wait, err := h.loginGuard.CheckIP(c.ClientIP(), middleware.AttemptRegister)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait, "Too many registration attempts, please try again later")
+		return
+	}
+
 	// Check if user already exists
 	var existingUser models.User
 	if err := h.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		if err := h.loginGuard.RecordFailure(c, middleware.AttemptRegister, req.Email, nil, "email_taken"); err != nil {
+			log.Printf("[WARN] Failed to record registration attempt: %v", err)
+		}
 		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
 		return
 	} else if err != gorm.ErrRecordNotFound {
@@ -120,7 +208,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Email:        req.Email,
 		PasswordHash: string(hashedPassword),
 		Name:         req.Name,
-		Role:         "user", // Default role
+		Role:         models.RoleUser, // Default role
 		IsActive:     true,
 	}
 
@@ -129,6 +217,23 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if err := h.loginGuard.RecordSuccess(c, middleware.AttemptRegister, user); err != nil {
+		log.Printf("[WARN] Failed to record registration attempt: %v", err)
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionRegister,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		After:      gin.H{"email": user.Email, "name": user.Name, "role": user.Role},
+		Actor:      &user,
+	})
+
+	// Send verification email; registration succeeds even if delivery fails,
+	// the user can ask for a new link later
+	if err := h.sendVerificationEmail(c.Request.Context(), &user); err != nil {
+		log.Printf("[WARN] Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
 	// Generate tokens
 	accessToken, refreshToken, err := h.authMiddleware.GenerateTokens(user)
 	if err != nil {
@@ -144,10 +249,39 @@ func (h *AuthHandler) Register(c *gin.Context) {
 			"email": user.Email,
 			"name":  user.Name,
 			"role":  user.Role,
+			"email_verified": user.EmailVerified,
 		},
 	})
 }
 
+// recordLoginFailure stores an audit record of a failed login
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, email string, user *models.User, reason string) {
+	if err := h.loginGuard.RecordFailure(c, middleware.AttemptLogin, email, user, reason); err != nil {
+		log.Printf("[WARN] Failed to record login failure for %s: %v", email, err)
+	}
+
+	entry := audit.Entry{
+		Action:     audit.ActionLoginFailed,
+		TargetType: audit.TargetUser,
+		After:      gin.H{"email": email, "reason": reason},
+		Actor:      user,
+	}
+	if user != nil {
+		entry.TargetID = user.ID
+	}
+	h.audit.Record(c, entry)
+}
+
+// respondTooManyAttempts aborts with 429 and a Retry-After header
+func respondTooManyAttempts(c *gin.Context, wait time.Duration, message string) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       message,
+		"retry_after": retryAfter,
+	})
+}
+
 // RefreshToken handles token refresh
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req struct {
@@ -222,6 +356,8 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
+	h.audit.Record(c, audit.Entry{Action: audit.ActionLogout, TargetType: audit.TargetUser, TargetID: userID})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
@@ -233,7 +369,16 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	response := gin.H{"user": user}
+	// Lets the frontend show who is really behind an impersonation session
+	if impersonatorID, ok := c.Get("impersonator_id"); ok {
+		response["impersonated_by"] = gin.H{
+			"id":    impersonatorID,
+			"email": c.GetString("impersonator_email"),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // UpdateProfile updates user profile
@@ -258,5 +403,16 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	entry := audit.Entry{
+		Action:     audit.ActionProfileUpdated,
+		TargetType: audit.TargetUser,
+		TargetID:   userID,
+		After:      gin.H{"name": req.Name},
+	}
+	if user, ok := c.Get("user"); ok {
+		entry.Before = gin.H{"name": user.(models.User).Name}
+	}
+	h.audit.Record(c, entry)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
 } 
\ No newline at end of file
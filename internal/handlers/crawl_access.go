@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/policy"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadSubject reads the authenticated user from the context and loads their organization memberships
+func loadSubject(c *gin.Context, db *gorm.DB) (policy.Subject, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return policy.Subject{}, false
+	}
+	userRole, _ := c.Get("user_role")
+
+	subject, err := policy.LoadSubject(db, userID.(uint), userRole.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return policy.Subject{}, false
+	}
+	return subject, true
+}
+
+// authorizeCrawl checks the policy for an action on a crawl and writes the error response when denied
+func authorizeCrawl(c *gin.Context, subject policy.Subject, action policy.Action, crawl models.CrawlResult) bool {
+	switch policy.Authorize(subject, action, policy.Crawl(crawl)) {
+	case nil:
+		return true
+	case policy.ErrForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to " + string(action) + " this crawl"})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
+	}
+	return false
+}
@@ -1,25 +1,40 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/crawler"
 	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/netguard"
+	"webcrawler-backend/internal/policy"
+	"webcrawler-backend/internal/quota"
+	"webcrawler-backend/internal/secrets"
+	"webcrawler-backend/internal/urlcanon"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
-	"time"
 )
 
 // CrawlHandler handles crawl-related API requests
 type CrawlHandler struct {
-	db *gorm.DB
+	db      *gorm.DB
+	crawler *crawler.Crawler
+	guard   *netguard.Guard
+	canon   *urlcanon.Canonicalizer
+	quotas  *quota.Service
+	audit   *audit.Logger
 }
 
 // NewCrawlHandler creates a new crawl handler
-func NewCrawlHandler(db *gorm.DB) *CrawlHandler {
-	return &CrawlHandler{db: db}
+func NewCrawlHandler(db *gorm.DB, crawler *crawler.Crawler, guard *netguard.Guard, canon *urlcanon.Canonicalizer, quotas *quota.Service, auditLog *audit.Logger) *CrawlHandler {
+	return &CrawlHandler{db: db, crawler: crawler, guard: guard, canon: canon, quotas: quotas, audit: auditLog}
 }
 
 // GetCrawlResults returns all crawl results with enhanced filtering
@@ -29,6 +44,7 @@ func (h *CrawlHandler) GetCrawlResults(c *gin.Context) {
 	url := c.Query("url")
 	title := c.Query("title")
 	hasLoginForm := c.Query("has_login_form")
+	organizationID := c.Query("organization_id")
 	dateFrom := c.Query("date_from")
 	dateTo := c.Query("date_to")
 	sortBy := c.DefaultQuery("sort_by", "created_at")
@@ -36,6 +52,14 @@ func (h *CrawlHandler) GetCrawlResults(c *gin.Context) {
 	limit := c.DefaultQuery("limit", "50")
 	offset := c.DefaultQuery("offset", "0")
 
+	// One filter per form class: has_classified_login_form, has_signup_form, ...
+	formFilters := make(map[string]string)
+	for _, class := range models.FormClasses {
+		if column := models.FormColumn(class); c.Query(column) != "" {
+			formFilters[column] = c.Query(column)
+		}
+	}
+
 	// Validate sort order
 	if sortOrder != "asc" && sortOrder != "desc" {
 		sortOrder = "desc"
@@ -48,6 +72,7 @@ func (h *CrawlHandler) GetCrawlResults(c *gin.Context) {
 		"url":        true,
 		"title":      true,
 		"status":     true,
+		"priority":   true,
 	}
 	if !allowedSortFields[sortBy] {
 		sortBy = "created_at"
@@ -56,20 +81,18 @@ func (h *CrawlHandler) GetCrawlResults(c *gin.Context) {
 	var results []models.CrawlResult
 	query := h.db.Model(&models.CrawlResult{})
 
-	// Check if user is authenticated and get their role
-	userRole, exists := c.Get("user_role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
 		return
 	}
 
-	// Apply user-based filtering (non-admin users only see their own crawls)
-	if userRole != "admin" {
-		userID, _ := c.Get("user_id")
-		query = query.Where("user_id = ?", userID)
-	}
+	// Apply visibility (own crawls, organization crawls, everything for admins)
+	query = policy.ScopeCrawls(subject, query)
 
 	// Apply filters
+	if organizationID != "" {
+		query = query.Where("organization_id = ?", organizationID)
+	}
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -83,6 +106,9 @@ func (h *CrawlHandler) GetCrawlResults(c *gin.Context) {
 		hasLogin := hasLoginForm == "true"
 		query = query.Where("has_login_form = ?", hasLogin)
 	}
+	for column, value := range formFilters {
+		query = query.Where(column+" = ?", value == "true")
+	}
 	if dateFrom != "" {
 		query = query.Where("created_at >= ?", dateFrom)
 	}
@@ -117,9 +143,9 @@ func (h *CrawlHandler) GetCrawlResults(c *gin.Context) {
 	countQuery := h.db.Model(&models.CrawlResult{})
 	
 	// Apply same filters to count query
-	if exists && userRole != "admin" {
-		userID, _ := c.Get("user_id")
-		countQuery = countQuery.Where("user_id = ?", userID)
+	countQuery = policy.ScopeCrawls(subject, countQuery)
+	if organizationID != "" {
+		countQuery = countQuery.Where("organization_id = ?", organizationID)
 	}
 	if status != "" {
 		countQuery = countQuery.Where("status = ?", status)
@@ -134,6 +160,9 @@ func (h *CrawlHandler) GetCrawlResults(c *gin.Context) {
 		hasLogin := hasLoginForm == "true"
 		countQuery = countQuery.Where("has_login_form = ?", hasLogin)
 	}
+	for column, value := range formFilters {
+		countQuery = countQuery.Where(column+" = ?", value == "true")
+	}
 	if dateFrom != "" {
 		countQuery = countQuery.Where("created_at >= ?", dateFrom)
 	}
@@ -156,25 +185,39 @@ func (h *CrawlHandler) GetCrawlResults(c *gin.Context) {
 			"url":           url,
 			"title":         title,
 			"has_login_form": hasLoginForm,
+			"organization_id": organizationID,
 			"date_from":     dateFrom,
 			"date_to":       dateTo,
 			"sort_by":       sortBy,
 			"sort_order":    sortOrder,
 		},
 	}
+	filters := response["filters"].(gin.H)
+	for column, value := range formFilters {
+		filters[column] = value
+	}
 	
 	c.JSON(http.StatusOK, response)
 }
 
-// GetCrawlResultByID returns a specific crawl result with its broken links
+// GetCrawlResultByID returns a specific crawl result with its broken links and redirect chains.
+// The issues of the chains are served paginated by GetCrawlIssues.
 func (h *CrawlHandler) GetCrawlResultByID(c *gin.Context) {
 	id := c.Param("id")
 	
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
 	var result models.CrawlResult
-	if err := h.db.Preload("BrokenLinks").First(&result, id).Error; err != nil {
+	if err := h.db.Preload("BrokenLinks").Preload("Redirects").First(&result, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl result not found"})
 		return
 	}
+	if !authorizeCrawl(c, subject, policy.ActionRead, result) {
+		return
+	}
 	
 	c.JSON(http.StatusOK, result)
 }
@@ -182,6 +225,20 @@ func (h *CrawlHandler) GetCrawlResultByID(c *gin.Context) {
 // GetBrokenLinks returns broken links for a specific crawl result
 func (h *CrawlHandler) GetBrokenLinks(c *gin.Context) {
 	crawlResultID := c.Param("id")
+
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
+	var crawl models.CrawlResult
+	if err := h.db.First(&crawl, crawlResultID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl result not found"})
+		return
+	}
+	if !authorizeCrawl(c, subject, policy.ActionRead, crawl) {
+		return
+	}
 	
 	var brokenLinks []models.BrokenLink
 	result := h.db.Where("crawl_result_id = ?", crawlResultID).Find(&brokenLinks)
@@ -196,7 +253,14 @@ func (h *CrawlHandler) GetBrokenLinks(c *gin.Context) {
 // CreateCrawlResult creates a new crawl result
 func (h *CrawlHandler) CreateCrawlResult(c *gin.Context) {
 	var request struct {
-		URL string `json:"url" binding:"required"`
+		URL            string `json:"url" binding:"required"`
+		OrganizationID *uint  `json:"organization_id"` // Optional: share the crawl with an organization
+		MaxPages       int    `json:"max_pages"`       // Optional: page budget, defaults to a single page
+		Priority       string `json:"priority"`        // Optional: low, normal (default), high, urgent
+		Scope          *crawler.Scope `json:"scope"`   // Optional: which discovered URLs are followed
+		Options        crawler.Options `json:"options"` // Optional: how pages are fetched
+		PresetID       *uint  `json:"preset_id"`       // Optional: start from a preset's options and scope
+		Auth           *crawler.Auth `json:"auth"`     // Optional: credentials of a site behind a login
 	}
 	
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -210,19 +274,122 @@ func (h *CrawlHandler) CreateCrawlResult(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid URL: %v", err)})
 		return
 	}
-	
-	// Get user ID from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+
+	// Reject internal targets early; the crawler checks every connection again
+	if parsedURL, err := url.Parse(normalizedURL); err == nil {
+		if err := h.guard.CheckURL(c.Request.Context(), parsedURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid URL: %v", err)})
+			return
+		}
+	}
+
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
+	// Credentials are encrypted when stored, so they require a master key
+	var credentials []byte
+	if request.Auth != nil {
+		if !secrets.Configured() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Authenticated crawling is not available: %v", secrets.ErrNotConfigured)})
+			return
+		}
+		seed, _ := url.Parse(normalizedURL)
+		if err := request.Auth.Validate(seed); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid auth: %v", err)})
+			return
+		}
+		credentials, _ = json.Marshal(request.Auth)
+	}
+
+	// A preset provides the base options and scope; the request overrides them field by field
+	var baseOptions crawler.Options
+	var baseScope *crawler.Scope
+	if request.PresetID != nil {
+		var preset models.CrawlPreset
+		if err := h.db.First(&preset, *request.PresetID).Error; err != nil || !canUsePreset(subject, preset) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Preset not found"})
+			return
+		}
+		if !preset.Options.IsNull() {
+			if err := json.Unmarshal(preset.Options, &baseOptions); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Preset has invalid stored options"})
+				return
+			}
+		}
+		if !preset.Scope.IsNull() {
+			baseScope = &crawler.Scope{}
+			if err := json.Unmarshal(preset.Scope, baseScope); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Preset has invalid stored scope"})
+				return
+			}
+		}
+	}
+
+	var scope models.JSON
+	if baseScope != nil || request.Scope != nil {
+		merged := crawler.Scope{}
+		if baseScope != nil {
+			merged = *baseScope
+		}
+		if request.Scope != nil {
+			merged = merged.Merge(*request.Scope)
+		}
+		normalized, err := merged.Normalize()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid scope: %v", err)})
+			return
+		}
+		scope, _ = json.Marshal(normalized)
+	}
+
+	if request.MaxPages > 0 {
+		if request.Options.MaxPages > 0 && request.Options.MaxPages != request.MaxPages {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_pages and options.max_pages differ"})
+			return
+		}
+		request.Options.MaxPages = request.MaxPages
+	}
+	options, err := baseOptions.Merge(request.Options).Normalize()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid options: %v", err)})
+		return
+	}
+
+	// Creating an organization crawl requires the editor role in it
+	if err := policy.Authorize(subject, policy.ActionCreate, policy.NewCrawl(request.OrganizationID)); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to create crawls in this organization"})
+		return
+	}
+
+	priority := models.PriorityNormal
+	if request.Priority != "" {
+		var valid bool
+		if priority, valid = models.ParsePriority(request.Priority); !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Priority must be low, normal, high or urgent"})
+			return
+		}
+	}
+	if priority == models.PriorityUrgent && policy.Authorize(subject, policy.ActionPrioritize, policy.NewCrawl(request.OrganizationID)) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and operators can create urgent crawls"})
 		return
 	}
 	
-	// Check for duplicate URL for this user
+	// Check for duplicate URL for this user, or within the organization. Crawls created
+	// before URLs were canonicalized were stored without the trailing slash.
 	var existingCrawl models.CrawlResult
-	if err := h.db.Where("url = ? AND user_id = ?", normalizedURL, userID).First(&existingCrawl).Error; err == nil {
+	spellings := []string{normalizedURL}
+	if legacy := strings.TrimSuffix(normalizedURL, "/"); legacy != normalizedURL {
+		spellings = append(spellings, legacy)
+	}
+	duplicateQuery := h.db.Where("url IN ? AND user_id = ? AND organization_id IS NULL", spellings, subject.UserID)
+	if request.OrganizationID != nil {
+		duplicateQuery = h.db.Where("url IN ? AND organization_id = ?", spellings, *request.OrganizationID)
+	}
+	if err := duplicateQuery.First(&existingCrawl).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{
-			"error": "URL already exists in crawl queue for this user",
+			"error": "URL already exists in crawl queue",
 			"existing_id": existingCrawl.ID,
 			"existing_status": existingCrawl.Status,
 		})
@@ -233,18 +400,45 @@ func (h *CrawlHandler) CreateCrawlResult(c *gin.Context) {
 		return
 	}
 	
-	// Create a new crawl result with queued status
-	userIDUint := userID.(uint)
-	crawlResult := models.CrawlResult{
-		URL:           normalizedURL,
-		Status:        models.StatusQueued,
-		UserID:        &userIDUint,
+	// Enforce the queue, daily and page quotas, and create the crawl in the same transaction
+	var crawlResult models.CrawlResult
+	var createErr error
+	err = h.quotas.Enqueue(c.MustGet("user").(models.User), request.OrganizationID, options.MaxPages, func(tx *gorm.DB, maxPages int) error {
+		options.MaxPages = maxPages
+		storedOptions, _ := json.Marshal(options)
+		
+		// Create a new crawl result with queued status
+		crawlResult = models.CrawlResult{
+			URL:            normalizedURL,
+			Status:         models.StatusQueued,
+			UserID:         &subject.UserID,
+			OrganizationID: request.OrganizationID,
+			MaxPages:       maxPages,
+			Priority:       priority,
+			Options:        storedOptions,
+			Scope:          scope,
+			Credentials:    credentials,
+		}
+		if request.Auth != nil {
+			crawlResult.AuthType = request.Auth.Type
+		}
+		createErr = tx.Create(&crawlResult).Error
+		return createErr
+	})
+	if createErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": createErr.Error()})
+		return
 	}
-	
-	if err := h.db.Create(&crawlResult).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err != nil {
+		respondQuotaError(c, err)
 		return
 	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionCrawlCreated,
+		TargetType: audit.TargetCrawl,
+		TargetID:   crawlResult.ID,
+		After:      gin.H{"url": crawlResult.URL, "organization_id": crawlResult.OrganizationID, "priority": crawlResult.Priority, "preset_id": request.PresetID, "auth_type": crawlResult.AuthType},
+	})
 	
 	c.JSON(http.StatusCreated, crawlResult)
 }
@@ -255,7 +449,7 @@ func (h *CrawlHandler) normalizeAndValidateURL(inputURL string) (string, error)
 	urlStr := strings.TrimSpace(inputURL)
 	
 	// Add scheme if missing
-	if !strings.HasPrefix(urlStr, "http://") && !strings.HasPrefix(urlStr, "https://") {
+	if lower := strings.ToLower(urlStr); !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
 		urlStr = "https://" + urlStr
 	}
 	
@@ -275,65 +469,65 @@ func (h *CrawlHandler) normalizeAndValidateURL(inputURL string) (string, error)
 	}
 	
 	// Only allow HTTP and HTTPS schemes
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+	if scheme := strings.ToLower(parsedURL.Scheme); scheme != "http" && scheme != "https" {
 		return "", fmt.Errorf("only HTTP and HTTPS schemes are supported")
 	}
 	
-	// Normalize the URL (remove default ports, etc.)
-	normalizedURL := parsedURL.String()
-	
-	// Remove trailing slash for consistency
-	normalizedURL = strings.TrimSuffix(normalizedURL, "/")
+	// Canonicalize so that equivalent spellings of a URL are detected as duplicates
+	canonicalURL, err := h.canon.Canonicalize(parsedURL)
+	if err != nil {
+		return "", err
+	}
 	
-	return normalizedURL, nil
+	return canonicalURL.String(), nil
 }
 
 func (h *CrawlHandler) ProcessQueuedCrawls(c *gin.Context) {
-    var crawl models.CrawlResult
-    // Find the oldest queued crawl
-    if err := h.db.Where("status = ?", models.StatusQueued).Order("created_at asc").First(&crawl).Error; err != nil {
-        c.JSON(200, gin.H{"message": "No queued crawls to process"})
+    subject, ok := loadSubject(c, h.db)
+    if !ok {
         return
     }
 
-    // Set to running
-    if err := h.db.Model(&crawl).Updates(map[string]interface{}{
-        "status":   models.StatusRunning,
-        "progress": 0,
-    }).Error; err != nil {
-        c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to set running for crawl %d: %v", crawl.ID, err)})
+    var queued []models.CrawlResult
+    if err := policy.ScopeCrawls(subject, h.db.Where("status = ?", models.StatusQueued)).Order("priority desc, created_at asc").Find(&queued).Error; err != nil {
+        c.JSON(500, gin.H{"error": "Database error"})
         return
     }
 
-    // Simulate crawl progress
-    success := true
-    for i := 20; i <= 100; i += 20 {
-        time.Sleep(300 * time.Millisecond) // Simulate work
-        if err := h.db.Model(&crawl).Update("progress", i).Error; err != nil {
-            success = false
+    // Take the oldest queued crawl the user may process (visible is not enough, e.g. organization viewers)
+    // whose owner has not reached the running quota
+    var crawl models.CrawlResult
+    found := false
+    for _, candidate := range queued {
+        if policy.Authorize(subject, policy.ActionProcess, policy.Crawl(candidate)) == nil && h.quotas.CheckStart(candidate) == nil {
+            crawl, found = candidate, true
             break
         }
     }
-
-    // Set to done or error
-    finalStatus := models.StatusDone
-    finalProgress := 100
-    if !success {
-        finalStatus = models.StatusError
-        finalProgress = 0
+    if !found {
+        c.JSON(200, gin.H{"message": "No queued crawls to process"})
+        return
     }
-    if err := h.db.Model(&crawl).Updates(map[string]interface{}{
-        "status":   finalStatus,
-        "progress": finalProgress,
-    }).Error; err != nil {
-        c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to finalize crawl %d: %v", crawl.ID, err)})
+
+    // Claim it unless a worker got there first
+    if !h.claimCrawl(c, &crawl) {
         return
     }
+    h.audit.Record(c, audit.Entry{
+        Action:     audit.ActionCrawlsProcessed,
+        TargetType: audit.TargetCrawl,
+        TargetID:   crawl.ID,
+        Before:     gin.H{"status": models.StatusQueued},
+        After:      gin.H{"status": models.StatusRunning},
+    })
+
+    // Crawling a site takes a while; clients poll the crawl for progress
+    go h.crawler.Run(context.Background(), &crawl)
 
     c.JSON(200, gin.H{
         "processed": crawl.ID,
-        "status":    finalStatus,
-        "message":   fmt.Sprintf("Processed crawl %d", crawl.ID),
+        "status":    models.StatusRunning,
+        "message":   fmt.Sprintf("Processing crawl %d", crawl.ID),
     })
 }
 
@@ -346,21 +540,34 @@ func (h *CrawlHandler) CrawlSingleURL(c *gin.Context) {
 		return
 	}
 
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
 	var crawl models.CrawlResult
 	if err := h.db.First(&crawl, idUint).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
 		return
 	}
+	if !authorizeCrawl(c, subject, policy.ActionProcess, crawl) {
+		return
+	}
 
 	fmt.Printf("Found crawl ID %d with status: %s\n", crawl.ID, crawl.Status)
 
-	// Allow re-processing if status is done or error
-	if crawl.Status == models.StatusDone || crawl.Status == models.StatusError {
-		fmt.Printf("Resetting crawl ID %d from status %s to queued\n", crawl.ID, crawl.Status)
-		if err := h.db.Model(&crawl).Updates(map[string]interface{}{
-			"status":   models.StatusQueued,
-			"progress": 0,
-		}).Error; err != nil {
+	if err := h.quotas.CheckStart(crawl); err != nil {
+		respondQuotaError(c, err)
+		return
+	}
+
+	switch crawl.Status {
+	case models.StatusRunning:
+		c.JSON(http.StatusConflict, gin.H{"error": "Crawl is already running"})
+		return
+	case models.StatusDone, models.StatusError, models.StatusStopped:
+		// Re-processing starts the crawl over
+		if err := crawler.Reset(h.db, crawl.ID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset crawl for re-processing"})
 			return
 		}
@@ -368,60 +575,57 @@ func (h *CrawlHandler) CrawlSingleURL(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated crawl"})
 			return
 		}
-		fmt.Printf("Crawl ID %d reset to status: %s\n", crawl.ID, crawl.Status)
 	}
 
-	// Set to running
-	fmt.Printf("Setting crawl ID %d to running\n", crawl.ID)
-	if err := h.db.Model(&crawl).Updates(map[string]interface{}{
-		"status":   models.StatusRunning,
-		"progress": 0,
-	}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set crawl to running"})
+	// Queued and paused crawls continue from their frontier
+	if !pauseAcknowledged(c, crawl) {
 		return
 	}
-
-	// Simulate crawl progress (for demo; replace with real crawl logic)
-	success := true
-	for i := 20; i <= 100; i += 20 {
-		time.Sleep(2 * time.Second) // Simulate work - increased from 300ms to 2s
-		fmt.Printf("Updating crawl ID %d progress to %d%%\n", crawl.ID, i)
-		if err := h.db.Model(&crawl).Update("progress", i).Error; err != nil {
-			success = false
-			break
-		}
-	}
-
-	// Set to done or error
-	finalStatus := models.StatusDone
-	finalProgress := 100
-	if !success {
-		finalStatus = models.StatusError
-		finalProgress = 0
-	}
-	fmt.Printf("Setting crawl ID %d to final status: %s\n", crawl.ID, finalStatus)
-	if err := h.db.Model(&crawl).Updates(map[string]interface{}{
-		"status":   finalStatus,
-		"progress": finalProgress,
-	}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finalize crawl"})
+	previousStatus := crawl.Status
+	if !h.claimCrawl(c, &crawl) {
 		return
 	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionCrawlProcessed,
+		TargetType: audit.TargetCrawl,
+		TargetID:   crawl.ID,
+		Before:     gin.H{"status": previousStatus},
+		After:      gin.H{"status": models.StatusRunning},
+	})
 
-	// Fetch updated crawl
-	if err := h.db.First(&crawl, idUint).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated crawl"})
-		return
-	}
+	go h.crawler.Run(context.Background(), &crawl)
 
-	fmt.Printf("Returning crawl ID %d with final status: %s\n", crawl.ID, crawl.Status)
 	c.JSON(http.StatusOK, crawl)
 }
 
+// claimCrawl sets a crawl to running unless its status changed in the meantime
+func (h *CrawlHandler) claimCrawl(c *gin.Context, crawl *models.CrawlResult) bool {
+	query := h.db.Model(&models.CrawlResult{}).Where("id = ? AND status = ?", crawl.ID, crawl.Status)
+	if crawl.Status == models.StatusPaused {
+		query = query.Where("paused_at IS NOT NULL")
+	}
+	result := query.Updates(map[string]interface{}{"status": models.StatusRunning, "paused_at": nil})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set crawl to running"})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Crawl status changed, please retry"})
+		return false
+	}
+	crawl.Status, crawl.PausedAt = models.StatusRunning, nil
+	return true
+}
+
 // StopCrawlByID stops a crawl by ID
 func (h *CrawlHandler) StopCrawlByID(c *gin.Context) {
 	id := c.Param("id")
 	
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
 	var result models.CrawlResult
 	if err := h.db.First(&result, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
@@ -429,28 +633,291 @@ func (h *CrawlHandler) StopCrawlByID(c *gin.Context) {
 	}
 	
 	// Check if user has permission to stop this crawl
-	userRole, _ := c.Get("user_role")
-	if userRole != "admin" {
-		userID, _ := c.Get("user_id")
-		if result.UserID != userID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to stop this crawl"})
-			return
-		}
+	if !authorizeCrawl(c, subject, policy.ActionStop, result) {
+		return
 	}
 	
-	// Update status to stopped
-	if err := h.db.Model(&result).Update("status", "stopped").Error; err != nil {
+	// Update status to stopped; a running crawl notices before its next page
+	previousStatus := result.Status
+	if err := h.db.Model(&result).Update("status", models.StatusStopped).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop crawl"})
 		return
 	}
+	if previousStatus != models.StatusRunning {
+		crawler.NewDBFrontier(h.db, result.ID, 0).Clear()
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionCrawlStopped,
+		TargetType: audit.TargetCrawl,
+		TargetID:   result.ID,
+		Before:     gin.H{"status": previousStatus},
+		After:      gin.H{"status": models.StatusStopped},
+	})
 	
 	c.JSON(http.StatusOK, gin.H{"message": "Crawl stopped successfully"})
 }
 
+// PauseCrawl pauses a queued or running crawl, keeping its frontier so it can resume
+func (h *CrawlHandler) PauseCrawl(c *gin.Context) {
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
+	var crawl models.CrawlResult
+	if err := h.db.First(&crawl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
+		return
+	}
+	if !authorizeCrawl(c, subject, policy.ActionStop, crawl) {
+		return
+	}
+	if crawl.Status != models.StatusQueued && crawl.Status != models.StatusRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot pause a %s crawl", crawl.Status)})
+		return
+	}
+
+	// A running crawl finishes its current page and then stops with its frontier intact;
+	// the worker sets paused_at once it has. A queued crawl has no worker to wait for.
+	previousStatus := crawl.Status
+	updates := map[string]interface{}{"status": models.StatusPaused, "paused_at": nil}
+	if previousStatus == models.StatusQueued {
+		now := time.Now()
+		updates["paused_at"] = now
+		crawl.PausedAt = &now
+	}
+	result := h.db.Model(&models.CrawlResult{}).
+		Where("id = ? AND status = ?", crawl.ID, previousStatus).
+		Updates(updates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pause crawl"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Crawl status changed, please retry"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionCrawlPaused,
+		TargetType: audit.TargetCrawl,
+		TargetID:   crawl.ID,
+		Before:     gin.H{"status": previousStatus},
+		After:      gin.H{"status": models.StatusPaused},
+	})
+
+	crawl.Status = models.StatusPaused
+	c.JSON(http.StatusOK, crawl)
+}
+
+// ResumeCrawl puts a paused crawl back in the queue; it continues from its frontier
+func (h *CrawlHandler) ResumeCrawl(c *gin.Context) {
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
+	var crawl models.CrawlResult
+	if err := h.db.First(&crawl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
+		return
+	}
+	if !authorizeCrawl(c, subject, policy.ActionProcess, crawl) {
+		return
+	}
+	if crawl.Status != models.StatusPaused {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only paused crawls can be resumed"})
+		return
+	}
+	if !pauseAcknowledged(c, crawl) {
+		return
+	}
+
+	// Requiring paused_at keeps a second run off the frontier while the first is still stopping
+	result := h.db.Model(&models.CrawlResult{}).
+		Where("id = ? AND status = ? AND paused_at IS NOT NULL", crawl.ID, models.StatusPaused).
+		Updates(map[string]interface{}{"status": models.StatusQueued, "paused_at": nil})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume crawl"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Crawl status changed, please retry"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionCrawlResumed,
+		TargetType: audit.TargetCrawl,
+		TargetID:   crawl.ID,
+		Before:     gin.H{"status": models.StatusPaused},
+		After:      gin.H{"status": models.StatusQueued},
+	})
+
+	crawl.Status, crawl.PausedAt = models.StatusQueued, nil
+	c.JSON(http.StatusOK, crawl)
+}
+
+// pauseAcknowledged responds 409 while a paused crawl's worker has not stopped yet
+func pauseAcknowledged(c *gin.Context, crawl models.CrawlResult) bool {
+	if crawl.Status == models.StatusPaused && crawl.PausedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Crawl is still finishing its current page, please retry"})
+		return false
+	}
+	return true
+}
+
+// GetCrawlPages returns the pages visited by a crawl
+func (h *CrawlHandler) GetCrawlPages(c *gin.Context) {
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
+	var crawl models.CrawlResult
+	if err := h.db.First(&crawl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
+		return
+	}
+	if !authorizeCrawl(c, subject, policy.ActionRead, crawl) {
+		return
+	}
+
+	var pages []models.CrawlPage
+	if err := h.db.Where("crawl_result_id = ?", crawl.ID).Order("id asc").Find(&pages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl pages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pages":         pages,
+		"pages_crawled": crawl.PagesCrawled,
+		"max_pages":     crawl.MaxPages,
+	})
+}
+
+// GetCrawlIssues returns the issues found by a crawl, filtered by type (comma-separated), URL
+// and redirect chain
+func (h *CrawlHandler) GetCrawlIssues(c *gin.Context) {
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
+	var crawl models.CrawlResult
+	if err := h.db.First(&crawl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
+		return
+	}
+	if !authorizeCrawl(c, subject, policy.ActionRead, crawl) {
+		return
+	}
+
+	query := h.db.Model(&models.CrawlIssue{}).Where("crawl_result_id = ?", crawl.ID)
+	if types := c.Query("type"); types != "" {
+		var filter []string
+		for _, t := range strings.Split(types, ",") {
+			if !slices.Contains(models.IssueTypes, t) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown issue type %q, expected one of %s", t, strings.Join(models.IssueTypes, ", "))})
+				return
+			}
+			filter = append(filter, t)
+		}
+		query = query.Where("type IN ?", filter)
+	}
+	if urlFilter := c.Query("url"); urlFilter != "" {
+		query = query.Where("url LIKE ?", "%"+urlFilter+"%")
+	}
+	if chain := c.Query("redirect_chain_id"); chain != "" {
+		chainID, err := strconv.ParseUint(chain, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid redirect_chain_id"})
+			return
+		}
+		query = query.Where("redirect_chain_id = ?", chainID)
+	}
+
+	limitInt, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offsetInt, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limitInt <= 0 || limitInt > 100 {
+		limitInt = 100
+	}
+
+	var totalCount int64
+	if err := query.Count(&totalCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl issues"})
+		return
+	}
+	var issues []models.CrawlIssue
+	if err := query.Order("id asc").Limit(limitInt).Offset(offsetInt).Find(&issues).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl issues"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   issues,
+		"counts": crawl.IssueCounts,
+		"pagination": gin.H{
+			"total":    totalCount,
+			"limit":    limitInt,
+			"offset":   offsetInt,
+			"has_more": offsetInt+limitInt < int(totalCount),
+		},
+	})
+}
+
+// SetCrawlPriority changes the queue priority of a crawl (admins and operators)
+func (h *CrawlHandler) SetCrawlPriority(c *gin.Context) {
+	var req struct {
+		Priority string `json:"priority" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Priority is required"})
+		return
+	}
+	priority, valid := models.ParsePriority(req.Priority)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Priority must be low, normal, high or urgent"})
+		return
+	}
+
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
+	var crawl models.CrawlResult
+	if err := h.db.First(&crawl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
+		return
+	}
+	if !authorizeCrawl(c, subject, policy.ActionPrioritize, crawl) {
+		return
+	}
+
+	previousPriority := crawl.Priority
+	if err := h.db.Model(&crawl).Update("priority", priority).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update priority"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionCrawlPrioritized,
+		TargetType: audit.TargetCrawl,
+		TargetID:   crawl.ID,
+		Before:     gin.H{"priority": previousPriority},
+		After:      gin.H{"priority": priority},
+	})
+
+	c.JSON(http.StatusOK, crawl)
+}
+
 // DeleteCrawlResult deletes a crawl result by ID
 func (h *CrawlHandler) DeleteCrawlResult(c *gin.Context) {
 	id := c.Param("id")
 	
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
 	var result models.CrawlResult
 	if err := h.db.First(&result, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
@@ -458,13 +925,8 @@ func (h *CrawlHandler) DeleteCrawlResult(c *gin.Context) {
 	}
 	
 	// Check if user has permission to delete this crawl
-	userRole, _ := c.Get("user_role")
-	if userRole != "admin" {
-		userID, _ := c.Get("user_id")
-		if result.UserID != userID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this crawl"})
-			return
-		}
+	if !authorizeCrawl(c, subject, policy.ActionDelete, result) {
+		return
 	}
 	
 	// Store crawl details before deletion
@@ -479,6 +941,13 @@ func (h *CrawlHandler) DeleteCrawlResult(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete crawl"})
 		return
 	}
+	crawler.NewDBFrontier(h.db, result.ID, 0).Clear()
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionCrawlDeleted,
+		TargetType: audit.TargetCrawl,
+		TargetID:   result.ID,
+		Before:     gin.H{"url": result.URL, "status": result.Status, "organization_id": result.OrganizationID},
+	})
 	
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Crawl deleted successfully",
@@ -497,13 +966,23 @@ func (h *CrawlHandler) GetStats(c *gin.Context) {
 		TotalBrokenLinks int64 `json:"total_broken_links"`
 	}
 	
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+	crawls := func() *gorm.DB {
+		return policy.ScopeCrawls(subject, h.db.Model(&models.CrawlResult{}))
+	}
+
 	// Count by status
-	h.db.Model(&models.CrawlResult{}).Count(&stats.TotalCrawls)
-	h.db.Model(&models.CrawlResult{}).Where("status = ?", models.StatusDone).Count(&stats.DoneCrawls)
-	h.db.Model(&models.CrawlResult{}).Where("status = ?", models.StatusError).Count(&stats.ErrorCrawls)
-	h.db.Model(&models.CrawlResult{}).Where("status = ?", models.StatusQueued).Count(&stats.QueuedCrawls)
-	h.db.Model(&models.CrawlResult{}).Where("status = ?", models.StatusRunning).Count(&stats.RunningCrawls)
-	h.db.Model(&models.BrokenLink{}).Count(&stats.TotalBrokenLinks)
+	crawls().Count(&stats.TotalCrawls)
+	crawls().Where("status = ?", models.StatusDone).Count(&stats.DoneCrawls)
+	crawls().Where("status = ?", models.StatusError).Count(&stats.ErrorCrawls)
+	crawls().Where("status = ?", models.StatusQueued).Count(&stats.QueuedCrawls)
+	crawls().Where("status = ?", models.StatusRunning).Count(&stats.RunningCrawls)
+	h.db.Model(&models.BrokenLink{}).
+		Where("crawl_result_id IN (?)", crawls().Select("id")).
+		Count(&stats.TotalBrokenLinks)
 	
 	c.JSON(http.StatusOK, stats)
 } 
\ No newline at end of file
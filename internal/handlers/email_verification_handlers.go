@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/mailer"
+	"webcrawler-backend/internal/middleware"
+	"webcrawler-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// emailVerificationTTL is how long a verification link stays valid
+	emailVerificationTTL = 48 * time.Hour
+	// verificationResendInterval is the minimum time between two verification emails
+	verificationResendInterval = time.Minute
+)
+
+// VerifyEmail marks the user's email as verified using the signed token from the verification link
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification token required"})
+		return
+	}
+
+	claims, err := h.authMiddleware.ValidatePurposeToken(req.Token, middleware.PurposeEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, claims.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	// The link is only valid for the address it was sent to
+	if !strings.EqualFold(user.Email, claims.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusOK, gin.H{"message": "Email already verified"})
+		return
+	}
+
+	if err := h.db.Model(&user).Update("email_verified", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionEmailVerified,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		After:      gin.H{"email": user.Email},
+		Actor:      &user,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmail sends a new verification link to the authenticated user
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	// Rate limit resends per user
+	if user.VerificationSentAt != nil {
+		if wait := verificationResendInterval - time.Since(*user.VerificationSentAt); wait > 0 {
+			respondTooManyAttempts(c, wait, "Verification email was sent recently, please wait before requesting another one")
+			return
+		}
+	}
+
+	if err := h.sendVerificationEmail(c.Request.Context(), &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// sendVerificationEmail signs a verification link, mails it and records when it was sent
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := h.authMiddleware.GeneratePurposeToken(*user, middleware.PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimSuffix(h.appBaseURL, "/"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %d hours.\n",
+			user.Name, link, int(emailVerificationTTL.Hours())),
+	}
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.VerificationSentAt = &now
+	return h.db.Model(user).Update("verification_sent_at", now).Error
+}
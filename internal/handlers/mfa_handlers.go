@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/middleware"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/totp"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// mfaChallengeTTL is how long the client has to answer the MFA challenge after the password step
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaIssuer is shown in authenticator apps
+	mfaIssuer = "WebCrawler"
+	// recoveryCodeCount is the number of recovery codes generated at once
+	recoveryCodeCount = 10
+)
+
+// respondMFAChallenge answers a successful password step with an MFA challenge token
+func (h *AuthHandler) respondMFAChallenge(c *gin.Context, user models.User) {
+	response, err := h.mfaChallenge(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate MFA challenge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// mfaChallenge returns the response body of an MFA challenge
+func (h *AuthHandler) mfaChallenge(user models.User) (gin.H, error) {
+	mfaToken, err := h.authMiddleware.GeneratePurposeToken(user, middleware.PurposeMFAChallenge, mfaChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+		"expires_in":   int(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// VerifyMFA completes a two-step login with a TOTP code or a recovery code
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA token and code or recovery code required"})
+		return
+	}
+
+	claims, err := h.authMiddleware.ValidatePurposeToken(req.MFAToken, middleware.PurposeMFAChallenge)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA challenge"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, claims.UserID).Error; err != nil || !user.IsActive || !user.MFAEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA challenge"})
+		return
+	}
+
+	// Codes are guessable, so they go through the same lockout as passwords
+	if locked, wait := h.loginGuard.CheckAccount(user); locked {
+		h.recordLoginFailure(c, user.Email, &user, "locked")
+		respondTooManyAttempts(c, wait, "Account temporarily locked due to too many failed login attempts")
+		return
+	} else if wait > 0 {
+		h.recordLoginFailure(c, user.Email, &user, "throttled")
+		respondTooManyAttempts(c, wait, "Too many failed login attempts, please try again later")
+		return
+	}
+
+	var valid bool
+	if req.Code != "" {
+		valid, err = h.consumeTOTP(&user, req.Code)
+	} else {
+		valid, err = h.consumeRecoveryCode(user.ID, req.RecoveryCode)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !valid {
+		h.recordLoginFailure(c, user.Email, &user, "invalid_mfa_code")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	h.completeLogin(c, user)
+}
+
+// EnrollMFA generates a new TOTP secret for the authenticated user.
+// MFA is not enabled until the secret is confirmed with ActivateMFA.
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	// A struct update, so the secret goes through the encrypting serializer
+	if err := h.db.Model(&user).Select("mfa_secret", "mfa_last_step").Updates(&models.User{MFASecret: secret}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(mfaIssuer, user.Email, secret),
+	})
+}
+
+// ActivateMFA confirms enrollment with a code from the authenticator app and returns recovery codes
+func (h *AuthHandler) ActivateMFA(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code required"})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.MFASecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+		return
+	}
+
+	valid, err := h.consumeTOTP(&user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("mfa_enabled", true).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{Action: audit.ActionMFAEnabled, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableMFA turns MFA off after checking the password and a current code
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password and code required"})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if h.authMiddleware.MFARequiredForRole(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	valid, err := h.consumeTOTP(&user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"mfa_enabled":   false,
+			"mfa_secret":    "",
+			"mfa_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{Action: audit.ActionMFADisabled, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a current code
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code required"})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	valid, err := h.consumeTOTP(&user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(h.db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{Action: audit.ActionRecoveryCodesRotated, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// currentUser reloads the authenticated user from the database
+func (h *AuthHandler) currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return user, false
+	}
+
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return user, false
+	}
+	return user, true
+}
+
+// consumeTOTP validates a code and records its time step so it cannot be replayed
+func (h *AuthHandler) consumeTOTP(user *models.User, code string) (bool, error) {
+	step, ok := totp.Validate(user.MFASecret, code, time.Now(), user.MFALastStep)
+	if !ok {
+		return false, nil
+	}
+
+	// Conditional update guards against two concurrent requests with the same code
+	result := h.db.Model(&models.User{}).
+		Where("id = ? AND mfa_last_step < ?", user.ID, step).
+		Update("mfa_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	user.MFALastStep = step
+	return result.RowsAffected == 1, nil
+}
+
+// consumeRecoveryCode marks a matching unused recovery code as used
+func (h *AuthHandler) consumeRecoveryCode(userID uint, code string) (bool, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+
+	result := h.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalized)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		log.Printf("[INFO] User %d logged in with a recovery code", userID)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// replaceRecoveryCodes deletes existing recovery codes and returns new ones (shown to the user once)
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(strings.ReplaceAll(code, "-", "")),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code formatted as xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	const charset = "abcdefghjkmnpqrstuvwxyz23456789" // No ambiguous characters
+	b := make([]byte, 10)
+	for i := range b {
+		// rand.Int draws uniformly, unlike a random byte modulo the charset length
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = charset[n.Int64()]
+	}
+	return fmt.Sprintf("%s-%s", b[:5], b[5:]), nil
+}
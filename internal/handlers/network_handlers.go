@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/netguard"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// NetworkHandler manages the networks crawls may reach despite the SSRF protection
+type NetworkHandler struct {
+	db    *gorm.DB
+	guard *netguard.Guard
+	audit *audit.Logger
+}
+
+// NewNetworkHandler creates a new network handler
+func NewNetworkHandler(db *gorm.DB, guard *netguard.Guard, auditLog *audit.Logger) *NetworkHandler {
+	return &NetworkHandler{db: db, guard: guard, audit: auditLog}
+}
+
+// ReloadAllowlist loads the admin-managed allowlist into the guard
+func (h *NetworkHandler) ReloadAllowlist() error {
+	var entries []models.NetworkAllowlistEntry
+	if err := h.db.Find(&entries).Error; err != nil {
+		return err
+	}
+	var allowlist netguard.Allowlist
+	for _, entry := range entries {
+		// Entries were validated when added
+		netguard.ParseAllowlistEntry(entry.Value, &allowlist)
+	}
+	h.guard.SetAllowlist(allowlist)
+	return nil
+}
+
+// ListAllowlist returns the allowlisted networks and hosts
+func (h *NetworkHandler) ListAllowlist(c *gin.Context) {
+	var entries []models.NetworkAllowlistEntry
+	if err := h.db.Order("value asc").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":              entries,
+		"protection_active": h.guard.Enabled(),
+	})
+}
+
+// AddAllowlistEntry allowlists a CIDR, IP address or host name
+func (h *NetworkHandler) AddAllowlistEntry(c *gin.Context) {
+	var req struct {
+		Value string `json:"value" binding:"required,max=255"`
+		Note  string `json:"note" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Value is required"})
+		return
+	}
+
+	var parsed netguard.Allowlist
+	if err := netguard.ParseAllowlistEntry(req.Value, &parsed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	value := req.Value
+	if len(parsed.Prefixes) > 0 {
+		value = parsed.Prefixes[0].String()
+	} else {
+		value = parsed.Hosts[0]
+	}
+
+	var count int64
+	h.db.Model(&models.NetworkAllowlistEntry{}).Where("value = ?", value).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Entry already exists"})
+		return
+	}
+
+	entry := models.NetworkAllowlistEntry{
+		Value:       value,
+		Note:        req.Note,
+		CreatedByID: c.MustGet("user_id").(uint),
+	}
+	if err := h.db.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add entry"})
+		return
+	}
+	if err := h.ReloadAllowlist(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload allowlist"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionAllowlistAdded,
+		TargetType: audit.TargetAllowlist,
+		TargetID:   entry.ID,
+		After:      gin.H{"value": entry.Value, "note": entry.Note},
+	})
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// DeleteAllowlistEntry removes an entry from the allowlist
+func (h *NetworkHandler) DeleteAllowlistEntry(c *gin.Context) {
+	var entry models.NetworkAllowlistEntry
+	if err := h.db.First(&entry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entry not found"})
+		return
+	}
+	if err := h.db.Delete(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete entry"})
+		return
+	}
+	if err := h.ReloadAllowlist(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload allowlist"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionAllowlistRemoved,
+		TargetType: audit.TargetAllowlist,
+		TargetID:   entry.ID,
+		Before:     gin.H{"value": entry.Value, "note": entry.Note},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Entry deleted successfully"})
+}
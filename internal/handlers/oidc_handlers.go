@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/oidc"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// oidcStateTTL is how long the user has to complete the login at the identity provider
+const oidcStateTTL = 10 * time.Minute
+
+// oidcStateCookie binds a login to the browser that started it, so a callback URL with
+// someone else's code and state cannot log this browser into their account
+const oidcStateCookie = "oidc_state"
+
+var (
+	errOIDCNoEmail         = errors.New("identity provider did not return an email address")
+	errOIDCUnverifiedEmail = errors.New("an account with this email already exists and the identity provider did not verify the address")
+)
+
+// OIDCHandler handles single sign-on through an OpenID Connect identity provider
+type OIDCHandler struct {
+	db                *gorm.DB
+	provider          *oidc.Provider
+	auth              *AuthHandler
+	roleMapping       map[string]string // IdP group -> role; empty disables group mapping
+	postLoginRedirect string            // Frontend URL receiving the tokens in the fragment; empty returns JSON
+}
+
+// NewOIDCHandler creates a new OIDC handler
+func NewOIDCHandler(db *gorm.DB, provider *oidc.Provider, auth *AuthHandler, roleMapping map[string]string, postLoginRedirect string) *OIDCHandler {
+	return &OIDCHandler{
+		db:                db,
+		provider:          provider,
+		auth:              auth,
+		roleMapping:       roleMapping,
+		postLoginRedirect: postLoginRedirect,
+	}
+}
+
+// ParseRoleMapping parses "group=role,group2=role2" into a map, skipping unknown roles
+func ParseRoleMapping(s string) map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || role == "" {
+			continue
+		}
+		if !models.IsValidRole(role) {
+			log.Printf("[WARN] Ignoring OIDC role mapping %q: unknown role", pair)
+			continue
+		}
+		mapping[group] = role
+	}
+	return mapping
+}
+
+// Login starts the authorization code flow and redirects to the identity provider
+func (h *OIDCHandler) Login(c *gin.Context) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	// Clean up abandoned logins
+	h.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+	if err := h.db.Create(&models.OIDCLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	authURL, err := h.provider.AuthCodeURL(c.Request.Context(), state, nonce, oidc.CodeChallengeS256(verifier))
+	if err != nil {
+		log.Printf("[ERROR] OIDC login failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	setOIDCStateCookie(c, state, int(oidcStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// setOIDCStateCookie sets (or, with a negative maxAge, clears) the state cookie for the
+// login and callback routes. Lax lets it through the top-level redirect back from the provider.
+func setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, path.Dir(c.Request.URL.Path), "", secure, true)
+}
+
+// Callback handles the redirect back from the identity provider, provisions the user and issues tokens
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		h.fail(c, http.StatusUnauthorized, "Login was rejected by the identity provider: "+errCode)
+		return
+	}
+
+	code := c.Query("code")
+	stateParam := c.Query("state")
+	if code == "" || stateParam == "" {
+		h.fail(c, http.StatusBadRequest, "Missing code or state")
+		return
+	}
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if cookieState == "" || subtle.ConstantTimeCompare([]byte(cookieState), []byte(stateParam)) != 1 {
+		h.fail(c, http.StatusBadRequest, "Login was not started in this browser")
+		return
+	}
+
+	// State is single-use: delete it and make sure we were the ones to do so
+	var state models.OIDCLoginState
+	if err := h.db.Where("state = ? AND expires_at > ?", stateParam, time.Now()).First(&state).Error; err != nil {
+		h.fail(c, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+	if result := h.db.Delete(&state); result.Error != nil || result.RowsAffected == 0 {
+		h.fail(c, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+
+	claims, err := h.provider.Exchange(c.Request.Context(), code, state.CodeVerifier)
+	if err != nil {
+		log.Printf("[WARN] OIDC code exchange failed: %v", err)
+		h.fail(c, http.StatusUnauthorized, "Failed to validate identity provider response")
+		return
+	}
+	if claims.Nonce != state.Nonce {
+		h.fail(c, http.StatusUnauthorized, "Invalid nonce")
+		return
+	}
+
+	user, err := h.provisionUser(c, claims)
+	if err == errOIDCNoEmail || err == errOIDCUnverifiedEmail {
+		h.fail(c, http.StatusForbidden, err.Error())
+		return
+	} else if err != nil {
+		log.Printf("[ERROR] OIDC user provisioning failed for subject %s: %v", claims.Subject, err)
+		h.fail(c, http.StatusInternalServerError, "Failed to provision user")
+		return
+	}
+	if !user.IsActive {
+		h.fail(c, http.StatusUnauthorized, "Account is deactivated")
+		return
+	}
+
+	var response gin.H
+	if user.MFAEnabled {
+		response, err = h.auth.mfaChallenge(*user)
+	} else {
+		response, err = h.auth.issueLoginTokens(c, *user)
+	}
+	if err != nil {
+		h.fail(c, http.StatusInternalServerError, "Failed to generate tokens")
+		return
+	}
+
+	h.respond(c, response)
+}
+
+// provisionUser finds the user linked to the IdP subject, links an existing account with the same
+// verified email, or creates a new one. Roles are synchronized from groups when a mapping is configured.
+// Links, new accounts and role changes are audited in the transaction that makes them.
+func (h *OIDCHandler) provisionUser(c *gin.Context, claims *oidc.Claims) (*models.User, error) {
+	externalSubject := h.provider.Issuer() + "|" + claims.Subject
+
+	var user models.User
+	err := h.db.Where("external_subject = ?", externalSubject).First(&user).Error
+	if err == gorm.ErrRecordNotFound {
+		if claims.Email == "" {
+			return nil, errOIDCNoEmail
+		}
+
+		err = h.db.Where("email = ?", claims.Email).First(&user).Error
+		switch {
+		case err == nil:
+			// Only link to an existing account when the IdP vouches for the address
+			if !claims.EmailVerified {
+				return nil, errOIDCUnverifiedEmail
+			}
+			err = h.db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Model(&user).Update("external_subject", externalSubject).Error; err != nil {
+					return err
+				}
+				return h.auth.audit.RecordTx(tx, c, audit.Entry{
+					Action:     audit.ActionAccountLinked,
+					TargetType: audit.TargetUser,
+					TargetID:   user.ID,
+					After:      gin.H{"issuer": h.provider.Issuer(), "subject": claims.Subject},
+					Actor:      &user,
+				})
+			})
+			if err != nil {
+				return nil, err
+			}
+		case err == gorm.ErrRecordNotFound:
+			name := claims.Name
+			if name == "" {
+				name = claims.Email
+			}
+			user = models.User{
+				Email:           claims.Email,
+				Name:            name,
+				Role:            models.RoleUser,
+				IsActive:        true,
+				EmailVerified:   claims.EmailVerified,
+				AuthProvider:    "oidc",
+				ExternalSubject: externalSubject,
+			}
+			err = h.db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				return h.auth.audit.RecordTx(tx, c, audit.Entry{
+					Action:     audit.ActionRegister,
+					TargetType: audit.TargetUser,
+					TargetID:   user.ID,
+					After:      gin.H{"email": user.Email, "name": user.Name, "role": user.Role, "auth_provider": user.AuthProvider},
+					Actor:      &user,
+				})
+			})
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if role := h.mapRole(claims.Groups); role != "" && role != user.Role {
+		previousRole := user.Role
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Update("role", role).Error; err != nil {
+				return err
+			}
+			return h.auth.audit.RecordTx(tx, c, audit.Entry{
+				Action:     audit.ActionUserRoleChanged,
+				TargetType: audit.TargetUser,
+				TargetID:   user.ID,
+				Before:     gin.H{"role": previousRole},
+				After:      gin.H{"role": role, "source": "oidc_groups"},
+				Actor:      &user,
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// mapRole returns the role granted by the user's groups, or "" when no mapping is configured.
+// With a mapping, users in no mapped group get the default "user" role; the most privileged mapped role wins.
+func (h *OIDCHandler) mapRole(groups []string) string {
+	if len(h.roleMapping) == 0 {
+		return ""
+	}
+
+	granted := make(map[string]bool)
+	for _, group := range groups {
+		if mapped, ok := h.roleMapping[group]; ok {
+			granted[mapped] = true
+		}
+	}
+	for _, role := range models.Roles {
+		if granted[role] {
+			return role
+		}
+	}
+	return models.RoleUser
+}
+
+// respond returns the login result as JSON, or redirects to the frontend with it in the URL fragment
+func (h *OIDCHandler) respond(c *gin.Context, response gin.H) {
+	if h.postLoginRedirect == "" {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	fragment := url.Values{}
+	for _, key := range []string{"access_token", "refresh_token", "mfa_token"} {
+		if value, ok := response[key].(string); ok {
+			fragment.Set(key, value)
+		}
+	}
+	if _, ok := response["mfa_required"]; ok {
+		fragment.Set("mfa_required", "true")
+	}
+	c.Redirect(http.StatusFound, h.postLoginRedirect+"#"+fragment.Encode())
+}
+
+// fail reports an error as JSON, or redirects to the frontend with the error in the URL fragment
+func (h *OIDCHandler) fail(c *gin.Context, status int, message string) {
+	if h.postLoginRedirect == "" {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	c.Redirect(http.StatusFound, h.postLoginRedirect+"#"+url.Values{"error": {message}}.Encode())
+}
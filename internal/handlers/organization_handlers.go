@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"net/http"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OrganizationHandler handles organization and membership API requests
+type OrganizationHandler struct {
+	db    *gorm.DB
+	audit *audit.Logger
+}
+
+// NewOrganizationHandler creates a new organization handler
+func NewOrganizationHandler(db *gorm.DB, auditLog *audit.Logger) *OrganizationHandler {
+	return &OrganizationHandler{db: db, audit: auditLog}
+}
+
+// ListOrganizations returns the organizations the user belongs to, with their role
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var memberships []models.Membership
+	if err := h.db.Preload("Organization").Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	organizations := make([]gin.H, 0, len(memberships))
+	for _, m := range memberships {
+		if m.Organization == nil {
+			continue // Organization was deleted
+		}
+		organizations = append(organizations, gin.H{
+			"id":         m.Organization.ID,
+			"name":       m.Organization.Name,
+			"role":       m.Role,
+			"created_at": m.Organization.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": organizations})
+}
+
+// CreateOrganization creates an organization owned by the current user
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required,max=255"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	organization := models.Organization{
+		Name:        req.Name,
+		CreatedByID: userID,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Membership{
+			OrganizationID: organization.ID,
+			UserID:         userID,
+			Role:           models.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionOrganizationCreated,
+		TargetType: audit.TargetOrganization,
+		TargetID:   organization.ID,
+		After:      gin.H{"name": organization.Name},
+	})
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+// GetOrganization returns an organization with its members
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	organization, _, ok := h.loadOrganization(c, models.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	var memberships []models.Membership
+	if err := h.db.Preload("User").Where("organization_id = ?", organization.ID).Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	members := make([]gin.H, 0, len(memberships))
+	for _, m := range memberships {
+		member := gin.H{"user_id": m.UserID, "role": m.Role, "joined_at": m.CreatedAt}
+		if m.User != nil {
+			member["email"] = m.User.Email
+			member["name"] = m.User.Name
+		}
+		members = append(members, member)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization": organization,
+		"members":      members,
+	})
+}
+
+// UpdateOrganization renames an organization (owners only)
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required,max=255"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	organization, _, ok := h.loadOrganization(c, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+
+	previousName := organization.Name
+	if err := h.db.Model(&organization).Update("name", req.Name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionOrganizationUpdated,
+		TargetType: audit.TargetOrganization,
+		TargetID:   organization.ID,
+		Before:     gin.H{"name": previousName},
+		After:      gin.H{"name": organization.Name},
+	})
+
+	c.JSON(http.StatusOK, organization)
+}
+
+// DeleteOrganization deletes an organization (owners only).
+// Its crawls are kept and become private to the users who created them.
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	organization, _, ok := h.loadOrganization(c, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.CrawlResult{}).
+			Where("organization_id = ?", organization.ID).
+			Update("organization_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organization.ID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&organization).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionOrganizationDeleted,
+		TargetType: audit.TargetOrganization,
+		TargetID:   organization.ID,
+		Before:     gin.H{"name": organization.Name},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+// AddMember adds an existing user to the organization by email (owners only)
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	var req struct {
+		Email string         `json:"email" binding:"required,email"`
+		Role  models.OrgRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email and a valid role (owner, editor, viewer) are required"})
+		return
+	}
+
+	organization, _, ok := h.loadOrganization(c, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var existing models.Membership
+	if err := h.db.Where("organization_id = ? AND user_id = ?", organization.ID, user.ID).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	} else if err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	membership := models.Membership{
+		OrganizationID: organization.ID,
+		UserID:         user.ID,
+		Role:           req.Role,
+	}
+	if err := h.db.Create(&membership).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionMemberAdded,
+		TargetType: audit.TargetMembership,
+		TargetID:   membership.ID,
+		After:      gin.H{"organization_id": organization.ID, "user_id": user.ID, "role": membership.Role},
+	})
+
+	c.JSON(http.StatusCreated, membership)
+}
+
+// UpdateMember changes a member's role (owners only)
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	var req struct {
+		Role models.OrgRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid role (owner, editor, viewer) is required"})
+		return
+	}
+
+	organization, _, ok := h.loadOrganization(c, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+
+	membership, ok := h.loadMembership(c, organization.ID)
+	if !ok {
+		return
+	}
+
+	if membership.Role == models.OrgRoleOwner && req.Role != models.OrgRoleOwner && !h.hasOtherOwner(organization.ID, membership.UserID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An organization must keep at least one owner"})
+		return
+	}
+
+	previousRole := membership.Role
+	if err := h.db.Model(&membership).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionMemberRoleChanged,
+		TargetType: audit.TargetMembership,
+		TargetID:   membership.ID,
+		Before:     gin.H{"organization_id": organization.ID, "user_id": membership.UserID, "role": previousRole},
+		After:      gin.H{"organization_id": organization.ID, "user_id": membership.UserID, "role": req.Role},
+	})
+
+	c.JSON(http.StatusOK, membership)
+}
+
+// RemoveMember removes a member (owners), or lets a member leave the organization
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	organization, role, ok := h.loadOrganization(c, models.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	membership, ok := h.loadMembership(c, organization.ID)
+	if !ok {
+		return
+	}
+
+	if membership.UserID != userID && role != models.OrgRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can remove other members"})
+		return
+	}
+	if membership.Role == models.OrgRoleOwner && !h.hasOtherOwner(organization.ID, membership.UserID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An organization must keep at least one owner"})
+		return
+	}
+
+	if err := h.db.Delete(&membership).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionMemberRemoved,
+		TargetType: audit.TargetMembership,
+		TargetID:   membership.ID,
+		Before:     gin.H{"organization_id": organization.ID, "user_id": membership.UserID, "role": membership.Role},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// loadOrganization loads the organization from the :id parameter and checks the user's membership.
+// Owners satisfy every role, editors satisfy editor and viewer. Admins may access any organization.
+func (h *OrganizationHandler) loadOrganization(c *gin.Context, minRole models.OrgRole) (models.Organization, models.OrgRole, bool) {
+	var organization models.Organization
+	if err := h.db.First(&organization, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return organization, "", false
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	if userRole, _ := c.Get("user_role"); userRole == models.RoleAdmin {
+		return organization, models.OrgRoleOwner, true
+	}
+
+	var membership models.Membership
+	if err := h.db.Where("organization_id = ? AND user_id = ?", organization.ID, userID).First(&membership).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return organization, "", false
+	}
+
+	allowed := membership.Role == models.OrgRoleOwner ||
+		minRole == models.OrgRoleViewer ||
+		(minRole == models.OrgRoleEditor && membership.Role.CanEdit())
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient organization permissions"})
+		return organization, membership.Role, false
+	}
+
+	return organization, membership.Role, true
+}
+
+// loadMembership loads the membership of the :user_id parameter in the organization
+func (h *OrganizationHandler) loadMembership(c *gin.Context, organizationID uint) (models.Membership, bool) {
+	var membership models.Membership
+	if err := h.db.Where("organization_id = ? AND user_id = ?", organizationID, c.Param("user_id")).First(&membership).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return membership, false
+	}
+	return membership, true
+}
+
+// hasOtherOwner reports whether the organization has an owner other than the given user
+func (h *OrganizationHandler) hasOtherOwner(organizationID, userID uint) bool {
+	var count int64
+	h.db.Model(&models.Membership{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", organizationID, models.OrgRoleOwner, userID).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/mailer"
+	"webcrawler-backend/internal/middleware"
+	"webcrawler-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// passwordResetTTL is how long a password reset link stays valid
+	passwordResetTTL = time.Hour
+	// passwordResetInterval is the minimum time between two password reset emails to an account
+	passwordResetInterval = time.Minute
+	// passwordResetSendTimeout bounds the background creation and delivery of a reset email
+	passwordResetSendTimeout = time.Minute
+)
+
+// ForgotPassword emails a single-use password reset link.
+// The response is the same whether or not the email exists, to avoid account enumeration:
+// the email is sent in the background, so the response time does not tell either.
+// Requests are limited per IP, and an account receives at most one email per passwordResetInterval.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	wait, err := h.loginGuard.CheckIP(c.ClientIP(), middleware.AttemptPasswordReset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait, "Too many password reset requests, please try again later")
+		return
+	}
+	if err := h.loginGuard.RecordRequest(c, middleware.AttemptPasswordReset, req.Email); err != nil {
+		log.Printf("[WARN] Failed to record password reset request: %v", err)
+	}
+
+	go h.sendPasswordReset(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+// sendPasswordReset creates a reset token for the account with this email, if any, and
+// mails the link unless one was sent within passwordResetInterval
+func (h *AuthHandler) sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTimeout)
+	defer cancel()
+	db := h.db.WithContext(ctx)
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil || !user.IsActive {
+		return
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		log.Printf("[WARN] Failed to generate password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Claim the send slot; the condition makes concurrent requests send a single email
+		claimed := tx.Model(&models.User{}).
+			Where("id = ? AND (password_reset_sent_at IS NULL OR password_reset_sent_at <= ?)", user.ID, now.Add(-passwordResetInterval)).
+			Update("password_reset_sent_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			return errResetRecentlySent
+		}
+
+		// Only the most recent link is valid
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(passwordResetTTL),
+		}).Error
+	})
+	if err == errResetRecentlySent {
+		return
+	} else if err != nil {
+		log.Printf("[WARN] Failed to create password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimSuffix(h.appBaseURL, "/"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone requested a password reset for your account. Open the link below to choose a new password:\n\n%s\n\nThe link expires in %d minutes. If you did not request this, you can ignore this email.\n",
+			user.Name, link, int(passwordResetTTL.Minutes())),
+	}
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Printf("[WARN] Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+}
+
+// ResetPassword sets a new password using a reset token and logs out every session
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var resetToken models.PasswordResetToken
+	if err := h.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(req.Token), time.Now()).
+		First(&resetToken).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// Consume the token; the condition makes concurrent uses fail
+		consumed := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).
+			Update("used_at", now)
+		if consumed.Error != nil {
+			return consumed.Error
+		}
+		if consumed.RowsAffected == 0 {
+			return errTokenAlreadyUsed
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).Updates(map[string]interface{}{
+			"password_hash":       string(hashedPassword),
+			"password_changed_at": now,
+		}).Error
+	})
+	if err == errTokenAlreadyUsed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := h.authMiddleware.RevokeAllRefreshTokens(resetToken.UserID); err != nil {
+		log.Printf("[WARN] Failed to revoke sessions of user %d after password reset: %v", resetToken.UserID, err)
+	}
+	// The request is unauthenticated, so the actor is the account owner holding the link
+	actor := models.User{ID: resetToken.UserID}
+	if err := h.db.Select("id", "email").First(&actor, resetToken.UserID).Error; err != nil {
+		log.Printf("[WARN] Failed to load user %d for the password reset audit entry: %v", resetToken.UserID, err)
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionPasswordReset,
+		TargetType: audit.TargetUser,
+		TargetID:   resetToken.UserID,
+		Actor:      &actor,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, please log in with your new password"})
+}
+
+// ChangePassword changes the authenticated user's password and revokes all other sessions.
+// The current session receives fresh tokens.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	if err := h.db.Model(&user).Updates(map[string]interface{}{
+		"password_hash":       string(hashedPassword),
+		"password_changed_at": now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	// Log out every session, then issue new tokens for this one
+	if err := h.authMiddleware.RevokeAllRefreshTokens(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{Action: audit.ActionPasswordChanged, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	accessToken, refreshToken, err := h.authMiddleware.GenerateTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Password changed successfully",
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
+}
+
+var (
+	errTokenAlreadyUsed  = fmt.Errorf("token already used")
+	errResetRecentlySent = fmt.Errorf("password reset email sent recently")
+)
+
+// generateSecureToken returns a random URL-safe token
+func generateSecureToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hex digest used to look up stored tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/crawler"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/policy"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PresetHandler handles crawl preset API requests
+type PresetHandler struct {
+	db    *gorm.DB
+	audit *audit.Logger
+}
+
+// NewPresetHandler creates a new preset handler
+func NewPresetHandler(db *gorm.DB, auditLog *audit.Logger) *PresetHandler {
+	return &PresetHandler{db: db, audit: auditLog}
+}
+
+// presetRequest is the body of preset create and update requests
+type presetRequest struct {
+	Name           string          `json:"name" binding:"required,max=100"`
+	Description    string          `json:"description" binding:"max=500"`
+	OrganizationID *uint           `json:"organization_id"` // Share the preset with an organization
+	Options        crawler.Options `json:"options"`
+	Scope          *crawler.Scope  `json:"scope"`
+}
+
+// ListPresets returns the user's presets and those of their organizations
+func (h *PresetHandler) ListPresets(c *gin.Context) {
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+
+	query := scopePresets(subject, h.db.Model(&models.CrawlPreset{}))
+	if orgID := c.Query("organization_id"); orgID != "" {
+		query = query.Where("organization_id = ?", orgID)
+	}
+
+	var presets []models.CrawlPreset
+	if err := query.Order("name asc").Find(&presets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch presets"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": presets})
+}
+
+// GetPreset returns a preset
+func (h *PresetHandler) GetPreset(c *gin.Context) {
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+	preset, ok := h.loadPreset(c, subject, false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, preset)
+}
+
+// CreatePreset creates a personal preset, or an organization preset for its editors
+func (h *PresetHandler) CreatePreset(c *gin.Context) {
+	var req presetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required (at most 100 characters)"})
+		return
+	}
+
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+	if req.OrganizationID != nil && !subject.Memberships[*req.OrganizationID].CanEdit() && !subject.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to create presets in this organization"})
+		return
+	}
+
+	preset := models.CrawlPreset{
+		Name:           req.Name,
+		Description:    req.Description,
+		OrganizationID: req.OrganizationID,
+		CreatedByID:    subject.UserID,
+	}
+	if req.OrganizationID == nil {
+		preset.UserID = &subject.UserID
+	}
+	if !applyPresetSettings(c, &preset, req) || !h.checkUniqueName(c, preset) {
+		return
+	}
+
+	if err := h.db.Create(&preset).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create preset"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionPresetCreated,
+		TargetType: audit.TargetPreset,
+		TargetID:   preset.ID,
+		After:      gin.H{"name": preset.Name, "organization_id": preset.OrganizationID},
+	})
+
+	c.JSON(http.StatusCreated, preset)
+}
+
+// UpdatePreset replaces the name, description and settings of a preset. Its owner cannot change.
+func (h *PresetHandler) UpdatePreset(c *gin.Context) {
+	var req presetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required (at most 100 characters)"})
+		return
+	}
+
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+	preset, ok := h.loadPreset(c, subject, true)
+	if !ok {
+		return
+	}
+	if req.OrganizationID != nil && (preset.OrganizationID == nil || *req.OrganizationID != *preset.OrganizationID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner of a preset cannot be changed"})
+		return
+	}
+
+	before := gin.H{"name": preset.Name, "options": preset.Options, "scope": preset.Scope}
+	preset.Name = req.Name
+	preset.Description = req.Description
+	if !applyPresetSettings(c, &preset, req) || !h.checkUniqueName(c, preset) {
+		return
+	}
+
+	if err := h.db.Model(&preset).Select("name", "description", "options", "scope").Updates(&preset).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preset"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionPresetUpdated,
+		TargetType: audit.TargetPreset,
+		TargetID:   preset.ID,
+		Before:     before,
+		After:      gin.H{"name": preset.Name, "options": preset.Options, "scope": preset.Scope},
+	})
+
+	c.JSON(http.StatusOK, preset)
+}
+
+// DeletePreset deletes a preset. Crawls created from it keep their settings.
+func (h *PresetHandler) DeletePreset(c *gin.Context) {
+	subject, ok := loadSubject(c, h.db)
+	if !ok {
+		return
+	}
+	preset, ok := h.loadPreset(c, subject, true)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(&preset).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete preset"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionPresetDeleted,
+		TargetType: audit.TargetPreset,
+		TargetID:   preset.ID,
+		Before:     gin.H{"name": preset.Name, "organization_id": preset.OrganizationID},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Preset deleted successfully"})
+}
+
+// loadPreset loads the preset in the URL if the subject may use it (or manage it, with manage)
+func (h *PresetHandler) loadPreset(c *gin.Context, subject policy.Subject, manage bool) (models.CrawlPreset, bool) {
+	var preset models.CrawlPreset
+	if err := h.db.First(&preset, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preset not found"})
+		return preset, false
+	}
+	if !canUsePreset(subject, preset) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preset not found"})
+		return preset, false
+	}
+	if manage && !canManagePreset(subject, preset) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this preset"})
+		return preset, false
+	}
+	return preset, true
+}
+
+// checkUniqueName rejects a second preset with the same name for the same owner
+func (h *PresetHandler) checkUniqueName(c *gin.Context, preset models.CrawlPreset) bool {
+	query := h.db.Model(&models.CrawlPreset{}).Where("name = ? AND id <> ?", preset.Name, preset.ID)
+	if preset.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *preset.OrganizationID)
+	} else {
+		query = query.Where("user_id = ? AND organization_id IS NULL", *preset.UserID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A preset with this name already exists"})
+		return false
+	}
+	return true
+}
+
+// applyPresetSettings validates the options and scope of a request and stores them on the preset
+func applyPresetSettings(c *gin.Context, preset *models.CrawlPreset, req presetRequest) bool {
+	options, err := req.Options.Normalize()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid options: %v", err)})
+		return false
+	}
+	if preset.Options, err = json.Marshal(options); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store preset options"})
+		return false
+	}
+
+	preset.Scope = nil
+	if req.Scope != nil {
+		scope, err := req.Scope.Normalize()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid scope: %v", err)})
+			return false
+		}
+		if preset.Scope, err = json.Marshal(scope); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store preset scope"})
+			return false
+		}
+	}
+	return true
+}
+
+// canUsePreset reports whether the subject may read a preset and create crawls from it
+func canUsePreset(subject policy.Subject, preset models.CrawlPreset) bool {
+	if subject.IsAdmin() {
+		return true
+	}
+	if preset.OrganizationID != nil {
+		_, member := subject.Memberships[*preset.OrganizationID]
+		return member
+	}
+	return preset.UserID != nil && *preset.UserID == subject.UserID
+}
+
+// canManagePreset reports whether the subject may change or delete a preset
+func canManagePreset(subject policy.Subject, preset models.CrawlPreset) bool {
+	if subject.IsAdmin() {
+		return true
+	}
+	if preset.OrganizationID != nil {
+		return subject.Memberships[*preset.OrganizationID].CanEdit()
+	}
+	return preset.UserID != nil && *preset.UserID == subject.UserID
+}
+
+// scopePresets restricts a preset query to the subject's own and organization presets
+func scopePresets(subject policy.Subject, query *gorm.DB) *gorm.DB {
+	if len(subject.Memberships) == 0 {
+		return query.Where("user_id = ? AND organization_id IS NULL", subject.UserID)
+	}
+	orgIDs := make([]uint, 0, len(subject.Memberships))
+	for id := range subject.Memberships {
+		orgIDs = append(orgIDs, id)
+	}
+	return query.Where("(user_id = ? AND organization_id IS NULL) OR organization_id IN ?", subject.UserID, orgIDs)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/quota"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// QuotaHandler handles usage reporting and quota administration
+type QuotaHandler struct {
+	db     *gorm.DB
+	quotas *quota.Service
+	audit  *audit.Logger
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(db *gorm.DB, quotas *quota.Service, auditLog *audit.Logger) *QuotaHandler {
+	return &QuotaHandler{db: db, quotas: quotas, audit: auditLog}
+}
+
+// GetUsage returns the user's quotas and consumption, and those of their organizations
+func (h *QuotaHandler) GetUsage(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	limits, err := h.quotas.UserLimits(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	usage, err := h.quotas.UserUsage(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var memberships []models.Membership
+	if err := h.db.Preload("Organization").Where("user_id = ?", user.ID).Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	organizations := make([]gin.H, 0, len(memberships))
+	for _, m := range memberships {
+		if m.Organization == nil {
+			continue // Organization was deleted
+		}
+		orgLimits, err := h.quotas.OrganizationLimits(m.OrganizationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		orgUsage, err := h.quotas.OrganizationUsage(m.OrganizationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		organizations = append(organizations, gin.H{
+			"id":     m.OrganizationID,
+			"name":   m.Organization.Name,
+			"limits": orgLimits,
+			"usage":  orgUsage,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"limits":        limits,
+		"usage":         usage,
+		"organizations": organizations,
+	})
+}
+
+// ListQuotas returns all quota overrides
+func (h *QuotaHandler) ListQuotas(c *gin.Context) {
+	var overrides []models.QuotaLimit
+	if err := h.db.Order("scope, subject").Find(&overrides).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"defaults": h.quotas.Defaults(),
+		"data":     overrides,
+	})
+}
+
+// SetQuota creates or replaces the quota override of a user, organization or role.
+// Omitted or null limits are inherited, 0 means unlimited.
+func (h *QuotaHandler) SetQuota(c *gin.Context) {
+	var req struct {
+		MaxQueued        *int `json:"max_queued"`
+		CrawlsPerDay     *int `json:"crawls_per_day"`
+		MaxPagesPerCrawl *int `json:"max_pages_per_crawl"`
+		MaxRunning       *int `json:"max_running"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	for _, limit := range []*int{req.MaxQueued, req.CrawlsPerDay, req.MaxPagesPerCrawl, req.MaxRunning} {
+		if limit != nil && *limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limits must be 0 (unlimited) or positive"})
+			return
+		}
+	}
+
+	scope, subject, ok := h.quotaSubject(c)
+	if !ok {
+		return
+	}
+
+	var override models.QuotaLimit
+	err := h.db.Where("scope = ? AND subject = ?", scope, subject).First(&override).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	var before interface{}
+	if err == nil {
+		before = override
+	}
+
+	override.Scope = scope
+	override.Subject = subject
+	override.MaxQueued = req.MaxQueued
+	override.CrawlsPerDay = req.CrawlsPerDay
+	override.MaxPagesPerCrawl = req.MaxPagesPerCrawl
+	override.MaxRunning = req.MaxRunning
+	if err := h.db.Save(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quota"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionQuotaUpdated,
+		TargetType: audit.TargetQuota,
+		TargetID:   scope + ":" + subject,
+		Before:     before,
+		After:      override,
+	})
+
+	c.JSON(http.StatusOK, override)
+}
+
+// DeleteQuota removes a quota override so the next scope applies again
+func (h *QuotaHandler) DeleteQuota(c *gin.Context) {
+	scope, subject := c.Param("scope"), c.Param("subject")
+
+	var override models.QuotaLimit
+	if err := h.db.Where("scope = ? AND subject = ?", scope, subject).First(&override).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quota not found"})
+		return
+	}
+	if err := h.db.Delete(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quota"})
+		return
+	}
+	h.audit.Record(c, audit.Entry{
+		Action:     audit.ActionQuotaDeleted,
+		TargetType: audit.TargetQuota,
+		TargetID:   scope + ":" + subject,
+		Before:     override,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quota deleted successfully"})
+}
+
+// quotaSubject validates the :scope and :subject parameters
+func (h *QuotaHandler) quotaSubject(c *gin.Context) (string, string, bool) {
+	scope, subject := c.Param("scope"), c.Param("subject")
+
+	var exists bool
+	switch scope {
+	case models.QuotaScopeRole:
+		exists = models.IsValidRole(subject)
+	case models.QuotaScopeUser, models.QuotaScopeOrganization:
+		id, err := strconv.ParseUint(subject, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Subject must be a numeric ID"})
+			return "", "", false
+		}
+		// Overrides are stored and looked up by the canonical decimal form
+		subject = strconv.FormatUint(id, 10)
+		if scope == models.QuotaScopeUser {
+			exists = h.db.First(&models.User{}, id).Error == nil
+		} else {
+			exists = h.db.First(&models.Organization{}, id).Error == nil
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scope must be user, organization or role"})
+		return "", "", false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quota subject not found"})
+		return "", "", false
+	}
+
+	return scope, subject, true
+}
+
+// respondQuotaError answers 429 with the exceeded quota, or 500 for other errors
+func respondQuotaError(c *gin.Context, err error) {
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+		return
+	}
+
+	response := gin.H{
+		"error": exceeded.Error(),
+		"quota": exceeded.Quota,
+		"limit": exceeded.Limit,
+		"used":  exceeded.Used,
+	}
+	if exceeded.RetryAfter > 0 {
+		retryAfter := int(math.Ceil(exceeded.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		response["retry_after"] = retryAfter
+	}
+	c.JSON(http.StatusTooManyRequests, response)
+}
@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Message is a plain-text email ready to be delivered
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails (verification links, password resets, ...)
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv builds the mailer selected by MAIL_DRIVER.
+//
+// Supported drivers:
+//   - smtp: deliver through SMTP_HOST/SMTP_PORT (default)
+//   - file: write .eml files into MAIL_OUTBOX_DIR, for local development
+//   - db:   store messages in the outbox_messages table, for tests
+func NewFromEnv(db *gorm.DB) (Mailer, error) {
+	from := getEnv("MAIL_FROM", "no-reply@webcrawler.local")
+
+	switch driver := strings.ToLower(getEnv("MAIL_DRIVER", "smtp")); driver {
+	case "smtp":
+		port, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %v", err)
+		}
+		return NewSMTPMailer(SMTPConfig{
+			Host:     getEnv("SMTP_HOST", "localhost"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}), nil
+	case "file":
+		return NewFileOutbox(getEnv("MAIL_OUTBOX_DIR", "tmp/outbox"), from)
+	case "db":
+		return NewDBOutbox(db, from), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+	}
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+	"webcrawler-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// FileOutbox writes every message as an .eml file instead of sending it
+type FileOutbox struct {
+	dir  string
+	from string
+}
+
+// NewFileOutbox creates a file outbox, creating the directory if needed
+func NewFileOutbox(dir, from string) (*FileOutbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %v", err)
+	}
+	return &FileOutbox{dir: dir, from: from}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Send writes the message to <dir>/<timestamp>-<recipient>.eml
+func (o *FileOutbox) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	if err := os.WriteFile(filepath.Join(o.dir, name), buildMIME(o.from, msg), 0o644); err != nil {
+		return fmt.Errorf("failed to write outbox file: %v", err)
+	}
+	return nil
+}
+
+// DBOutbox stores every message in the outbox_messages table instead of sending it
+type DBOutbox struct {
+	db   *gorm.DB
+	from string
+}
+
+// NewDBOutbox creates a database-backed outbox
+func NewDBOutbox(db *gorm.DB, from string) *DBOutbox {
+	return &DBOutbox{db: db, from: from}
+}
+
+// Send stores the message
+func (o *DBOutbox) Send(ctx context.Context, msg Message) error {
+	record := models.OutboxMessage{
+		From:    o.from,
+		To:      msg.To,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+	}
+	if err := o.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return fmt.Errorf("failed to store outbox message: %v", err)
+	}
+	return nil
+}
@@ -17,22 +17,40 @@ type JWTClaims struct {
 	UserID uint   `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// Purpose is empty for access tokens and set for single-purpose tokens
+	// (e.g. email verification) so they can never be used as access tokens
+	Purpose string `json:"purpose,omitempty"`
+	// Act identifies the admin acting as the user in an impersonation token (RFC 8693 actor claim)
+	Act *ActorClaim `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ActorClaim is the real user behind an impersonation token
+type ActorClaim struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// Token purposes for single-purpose tokens
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposeMFAChallenge      = "mfa_challenge"
+)
+
 // AuthMiddleware handles JWT authentication
 type AuthMiddleware struct {
 	db          *gorm.DB
-	jwtSecret   []byte
+	keys        *KeyManager
 	accessTokenExpiry  time.Duration
 	refreshTokenExpiry time.Duration
+	mfaRequiredRoles   []string
 }
 
 // NewAuthMiddleware creates a new auth middleware instance
-func NewAuthMiddleware(db *gorm.DB, jwtSecret string) *AuthMiddleware {
+func NewAuthMiddleware(db *gorm.DB, keys *KeyManager) *AuthMiddleware {
 	return &AuthMiddleware{
 		db:                 db,
-		jwtSecret:          []byte(jwtSecret),
+		keys:               keys,
 		accessTokenExpiry:  15 * time.Minute,  // 15 minutes
 		refreshTokenExpiry: 7 * 24 * time.Hour, // 7 days
 	}
@@ -41,50 +59,112 @@ func NewAuthMiddleware(db *gorm.DB, jwtSecret string) *AuthMiddleware {
 // AuthRequired middleware that requires authentication
 func (am *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token, err := am.extractToken(c)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
-			c.Abort()
+		if !am.authenticate(c, true) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		claims, err := am.validateToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+// AuthRequiredForMFASetup middleware that requires authentication but lets users whose role
+// requires MFA through before they have enrolled, so they can set it up
+func (am *AuthMiddleware) AuthRequiredForMFASetup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !am.authenticate(c, false) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Check if user still exists and is active
-		var user models.User
-		if err := am.db.First(&user, claims.UserID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-			c.Abort()
-			return
+// SetMFARequiredRoles sets the roles that must have MFA enabled to use the API
+func (am *AuthMiddleware) SetMFARequiredRoles(roles ...string) {
+	am.mfaRequiredRoles = roles
+}
+
+// MFARequiredForRole reports whether users with the role must enable MFA
+func (am *AuthMiddleware) MFARequiredForRole(role string) bool {
+	for _, r := range am.mfaRequiredRoles {
+		if r == role {
+			return true
 		}
+	}
+	return false
+}
 
-		if !user.IsActive {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
+// authenticate validates the access token, loads the user into the context and
+// aborts the request on failure. It returns whether the request may continue.
+func (am *AuthMiddleware) authenticate(c *gin.Context, enforceMFA bool) bool {
+	token, err := am.extractToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		c.Abort()
+		return false
+	}
+
+	claims, err := am.validateToken(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return false
+	}
+
+	// Check if user still exists and is active
+	var user models.User
+	if err := am.db.First(&user, claims.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.Abort()
+		return false
+	}
+
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
+		c.Abort()
+		return false
+	}
+
+	if issuedBeforePasswordChange(claims, user) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
+		c.Abort()
+		return false
+	}
+
+	// The admin behind an impersonation token must still be an active admin
+	if claims.Act != nil {
+		var actor models.User
+		if err := am.db.First(&actor, claims.Act.UserID).Error; err != nil || !actor.IsActive || actor.Role != models.RoleAdmin {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Impersonation is no longer allowed"})
 			c.Abort()
-			return
+			return false
 		}
+		c.Set("impersonator_id", actor.ID)
+		c.Set("impersonator_email", actor.Email)
+	}
 
-		// Set user info in context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
-		c.Set("user", user)
-
-		c.Next()
+	// MFA enrollment is the impersonated user's business, not the admin's
+	if enforceMFA && claims.Act == nil && !user.MFAEnabled && am.MFARequiredForRole(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                   "Two-factor authentication must be enabled for your role",
+			"mfa_enrollment_required": true,
+		})
+		c.Abort()
+		return false
 	}
+
+	// Set user info in context. The role comes from the database so role changes apply immediately.
+	c.Set("user_id", claims.UserID)
+	c.Set("user_email", claims.Email)
+	c.Set("user_role", user.Role)
+	c.Set("user", user)
+
+	return true
 }
 
 // RoleRequired middleware that requires specific role
 func (am *AuthMiddleware) RoleRequired(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// First apply authentication
-		am.AuthRequired()(c)
-		if c.IsAborted() {
+		// First apply authentication, unless an earlier AuthRequired already did
+		if _, authenticated := c.Get("user_id"); !authenticated && !am.authenticate(c, true) {
 			return
 		}
 
@@ -114,6 +194,30 @@ func (am *AuthMiddleware) RoleRequired(roles ...string) gin.HandlerFunc {
 	}
 }
 
+// EmailVerifiedRequired middleware that requires the authenticated user to have a verified email.
+// Must be used after AuthRequired.
+func (am *AuthMiddleware) EmailVerifiedRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		if !user.(models.User).EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                       "Email address must be verified first",
+				"email_verification_required": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // OptionalAuth middleware that doesn't require authentication but sets user info if available
 func (am *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -124,7 +228,7 @@ func (am *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 		}
 
 		claims, err := am.validateToken(token)
-		if err != nil {
+		if err != nil || claims.Act != nil { // Impersonation tokens need AuthRequired
 			c.Next()
 			return
 		}
@@ -141,6 +245,11 @@ func (am *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			return
 		}
 
+		if issuedBeforePasswordChange(claims, user) {
+			c.Next()
+			return
+		}
+
 		// Set user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
@@ -165,8 +274,7 @@ func (am *AuthMiddleware) GenerateTokens(user models.User) (string, string, erro
 		},
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(am.jwtSecret)
+	accessTokenString, err := am.keys.Sign(accessClaims)
 	if err != nil {
 		return "", "", err
 	}
@@ -192,6 +300,74 @@ func (am *AuthMiddleware) GenerateTokens(user models.User) (string, string, erro
 	return accessTokenString, refreshToken, nil
 }
 
+// GenerateImpersonationToken generates a short-lived access token for the user carrying the admin as actor.
+// No refresh token is issued, so the session ends when the token expires.
+func (am *AuthMiddleware) GenerateImpersonationToken(user, admin models.User, ttl time.Duration) (string, error) {
+	claims := JWTClaims{
+		UserID: user.ID,
+		Email:  user.Email,
+		Role:   user.Role,
+		Act:    &ActorClaim{UserID: admin.ID, Email: admin.Email},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "webcrawler-api",
+		},
+	}
+
+	return am.keys.Sign(claims)
+}
+
+// NotImpersonating middleware that blocks sensitive operations for impersonation tokens.
+// Must run after AuthRequired.
+func (am *AuthMiddleware) NotImpersonating() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsImpersonating(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This operation is not allowed while impersonating a user"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsImpersonating reports whether the request was authenticated with an impersonation token
+func IsImpersonating(c *gin.Context) bool {
+	_, exists := c.Get("impersonator_id")
+	return exists
+}
+
+// GeneratePurposeToken generates a signed single-purpose token (e.g. an email verification link)
+func (am *AuthMiddleware) GeneratePurposeToken(user models.User, purpose string, ttl time.Duration) (string, error) {
+	claims := JWTClaims{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Role:    user.Role,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "webcrawler-api",
+		},
+	}
+
+	return am.keys.Sign(claims)
+}
+
+// ValidatePurposeToken validates a single-purpose token and returns its claims
+func (am *AuthMiddleware) ValidatePurposeToken(tokenString string, purpose string) (*JWTClaims, error) {
+	claims, err := am.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("invalid token purpose")
+	}
+
+	return claims, nil
+}
+
 // ValidateRefreshToken validates a refresh token
 func (am *AuthMiddleware) ValidateRefreshToken(refreshToken string, userID uint) (bool, error) {
 	var token models.RefreshToken
@@ -221,6 +397,28 @@ func (am *AuthMiddleware) RevokeRefreshToken(refreshToken string, userID uint) e
 	return am.db.Model(&token).Update("is_revoked", true).Error
 }
 
+// RevokeAllRefreshTokens revokes every active refresh token of a user (logs out all sessions)
+func (am *AuthMiddleware) RevokeAllRefreshTokens(userID uint) error {
+	return am.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND is_revoked = ?", userID, false).
+		Update("is_revoked", true).Error
+}
+
+// issuedBeforePasswordChange reports whether the token predates the user's last password change.
+// JWT timestamps have second precision, so the change time is truncated to the second.
+func issuedBeforePasswordChange(claims *JWTClaims, user models.User) bool {
+	if user.PasswordChangedAt == nil || claims.IssuedAt == nil {
+		return false
+	}
+	return claims.IssuedAt.Time.Before(user.PasswordChangedAt.Truncate(time.Second))
+}
+
+// JWKS serves the public signing keys so other services can verify our tokens
+func (am *AuthMiddleware) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, am.keys.JWKS())
+}
+
 // extractToken extracts JWT token from Authorization header
 func (am *AuthMiddleware) extractToken(c *gin.Context) (string, error) {
 	authHeader := c.GetHeader("Authorization")
@@ -236,14 +434,25 @@ func (am *AuthMiddleware) extractToken(c *gin.Context) (string, error) {
 	return parts[1], nil
 }
 
-// validateToken validates JWT token and returns claims
+// validateToken validates an access token and returns claims
 func (am *AuthMiddleware) validateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return am.jwtSecret, nil
-	})
+	claims, err := am.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Single-purpose tokens are not access tokens
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
+
+// parseToken verifies the JWT signature and returns claims
+func (am *AuthMiddleware) parseToken(tokenString string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, am.keys.Keyfunc,
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,443 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"webcrawler-backend/internal/config"
+	"webcrawler-backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Supported signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// KeyConfig configures where signing keys come from
+type KeyConfig struct {
+	// Algorithm for generated keys: RS256 or EdDSA
+	Algorithm string
+
+	// PrivateKeyFiles are PEM files (PKCS#8, or PKCS#1 for RSA) with RSA or Ed25519 keys managed by operators.
+	// The first key signs, the others are only accepted for verification,
+	// which allows manual rotation with overlap.
+	PrivateKeyFiles []string
+
+	// RotationInterval enables database-managed keys rotated automatically at this interval
+	RotationInterval time.Duration
+	// Overlap is how long a replaced key is still accepted for verification.
+	// It must exceed the lifetime of the longest-lived token.
+	Overlap time.Duration
+	// Prepublish is how long a new key is published in the JWKS before it signs tokens,
+	// so every instance and consumer has loaded it in time
+	Prepublish time.Duration
+
+	// Production refuses to start without a configured key
+	Production bool
+}
+
+// KeyConfigFromEnv reads the JWT_* and APP_ENV environment variables
+func KeyConfigFromEnv() KeyConfig {
+	config := KeyConfig{
+		Algorithm:        AlgEdDSA,
+		RotationInterval: config.EnvDuration("JWT_KEY_ROTATION_INTERVAL", 0),
+		Overlap:          config.EnvDuration("JWT_KEY_OVERLAP", 72*time.Hour),
+		Prepublish:       config.EnvDuration("JWT_KEY_PREPUBLISH", 5*time.Minute),
+		Production:       os.Getenv("APP_ENV") == "production",
+	}
+	if alg := os.Getenv("JWT_SIGNING_ALG"); alg != "" {
+		config.Algorithm = alg
+	}
+	for _, file := range strings.Split(os.Getenv("JWT_PRIVATE_KEY_FILES"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			config.PrivateKeyFiles = append(config.PrivateKeyFiles, file)
+		}
+	}
+	return config
+}
+
+// signingKey is a private key identified by its kid
+type signingKey struct {
+	kid         string
+	algorithm   string
+	private     crypto.Signer
+	activatesAt time.Time
+	retiresAt   *time.Time
+}
+
+func (k *signingKey) method() jwt.SigningMethod {
+	if k.algorithm == AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// KeyManager holds the keys used to sign and verify tokens
+type KeyManager struct {
+	db     *gorm.DB
+	config KeyConfig
+
+	mu      sync.RWMutex
+	keys    []*signingKey
+	managed bool // Keys live in the database and are rotated automatically
+}
+
+// NewKeyManager loads signing keys from files or the database. Without any configured
+// key it generates an ephemeral one for development, and fails in production.
+func NewKeyManager(db *gorm.DB, config KeyConfig) (*KeyManager, error) {
+	if config.Algorithm != AlgRS256 && config.Algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q (use %s or %s)", config.Algorithm, AlgRS256, AlgEdDSA)
+	}
+
+	km := &KeyManager{db: db, config: config}
+
+	switch {
+	case len(config.PrivateKeyFiles) > 0:
+		for i, file := range config.PrivateKeyFiles {
+			key, err := loadKeyFile(file)
+			if err != nil {
+				return nil, err
+			}
+			// Only the first file signs; the others are kept for verification
+			if i > 0 {
+				key.activatesAt = time.Unix(0, 0)
+			} else {
+				key.activatesAt = time.Unix(1, 0)
+			}
+			km.keys = append(km.keys, key)
+		}
+
+	case config.RotationInterval > 0:
+		km.managed = true
+		if err := km.reload(); err != nil {
+			return nil, err
+		}
+		if km.activeKey() == nil {
+			if err := km.Rotate(); err != nil {
+				return nil, err
+			}
+		}
+
+	case config.Production:
+		return nil, fmt.Errorf("no JWT signing key configured: set JWT_PRIVATE_KEY_FILES or JWT_KEY_ROTATION_INTERVAL")
+
+	default:
+		key, err := generateKey(config.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		km.keys = []*signingKey{key}
+		log.Printf("[WARN] Using an ephemeral JWT signing key, tokens will not survive a restart. Set JWT_PRIVATE_KEY_FILES or JWT_KEY_ROTATION_INTERVAL for production.")
+	}
+
+	return km, nil
+}
+
+// Sign signs the claims with the active key
+func (km *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	km.mu.RLock()
+	key := km.activeKey()
+	km.mu.RUnlock()
+	if key == nil {
+		return "", fmt.Errorf("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Keyfunc returns the public key for the token's kid, for use with jwt.Parse
+func (km *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range km.keys {
+		if key.kid != kid {
+			continue
+		}
+		if key.retiresAt != nil && now.After(*key.retiresAt) {
+			return nil, fmt.Errorf("signing key %q is retired", kid)
+		}
+		if token.Method.Alg() != key.method().Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.private.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWKS returns the public keys accepted for verification, in JSON Web Key Set format
+func (km *KeyManager) JWKS() map[string]interface{} {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]map[string]string, 0, len(km.keys))
+	for _, key := range km.keys {
+		if key.retiresAt != nil && now.After(*key.retiresAt) {
+			continue
+		}
+		jwk := map[string]string{"kid": key.kid, "use": "sig", "alg": key.algorithm}
+		switch pub := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		keys = append(keys, jwk)
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// Rotate generates a new database-managed key. It is published immediately, signs after
+// the prepublish delay, and the keys it replaces are retired after the overlap.
+func (km *KeyManager) Rotate() error {
+	if !km.managed {
+		return fmt.Errorf("key rotation requires database-managed keys")
+	}
+
+	key, err := generateKey(km.config.Algorithm)
+	if err != nil {
+		return err
+	}
+	privatePEM, err := encodePrivateKey(key.private)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	activatesAt := now.Add(km.config.Prepublish)
+	// The very first key must sign right away
+	km.mu.RLock()
+	if km.activeKey() == nil {
+		activatesAt = now
+	}
+	km.mu.RUnlock()
+	retiresAt := activatesAt.Add(km.config.Overlap)
+
+	err = km.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SigningKey{}).
+			Where("retires_at IS NULL").
+			Update("retires_at", retiresAt).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SigningKey{
+			KID:           key.kid,
+			Algorithm:     key.algorithm,
+			PrivateKeyPEM: string(privatePEM),
+			ActivatesAt:   activatesAt,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store signing key: %v", err)
+	}
+
+	log.Printf("[INFO] Generated JWT signing key %s, active from %s", key.kid, activatesAt.Format(time.RFC3339))
+	return km.reload()
+}
+
+// StartRotation rotates database-managed keys on schedule and reloads keys created by
+// other instances, until the context is cancelled
+func (km *KeyManager) StartRotation(ctx context.Context) {
+	if !km.managed {
+		return
+	}
+
+	// Reload often enough that keys from other instances are known before they sign
+	interval := km.config.Prepublish / 2
+	if interval <= 0 || interval > time.Minute {
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := km.reload(); err != nil {
+					log.Printf("[ERROR] Failed to reload JWT signing keys: %v", err)
+					continue
+				}
+				if km.rotationDue() {
+					if err := km.Rotate(); err != nil {
+						log.Printf("[ERROR] Failed to rotate JWT signing key: %v", err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// rotationDue reports whether the newest key is older than the rotation interval
+func (km *KeyManager) rotationDue() bool {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	var newest time.Time
+	for _, key := range km.keys {
+		if key.activatesAt.After(newest) {
+			newest = key.activatesAt
+		}
+	}
+	return time.Since(newest) >= km.config.RotationInterval
+}
+
+// reload replaces the in-memory keys with the non-retired database keys
+func (km *KeyManager) reload() error {
+	var records []models.SigningKey
+	if err := km.db.Where("retires_at IS NULL OR retires_at > ?", time.Now()).Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to load signing keys: %v", err)
+	}
+
+	keys := make([]*signingKey, 0, len(records))
+	for _, record := range records {
+		private, err := parsePrivateKey([]byte(record.PrivateKeyPEM))
+		if err != nil {
+			return fmt.Errorf("invalid signing key %s: %v", record.KID, err)
+		}
+		keys = append(keys, &signingKey{
+			kid:         record.KID,
+			algorithm:   record.Algorithm,
+			private:     private,
+			activatesAt: record.ActivatesAt,
+			retiresAt:   record.RetiresAt,
+		})
+	}
+
+	km.mu.Lock()
+	km.keys = keys
+	km.mu.Unlock()
+	return nil
+}
+
+// activeKey returns the most recently activated key that may sign. Callers hold km.mu.
+func (km *KeyManager) activeKey() *signingKey {
+	now := time.Now()
+	candidates := make([]*signingKey, 0, len(km.keys))
+	for _, key := range km.keys {
+		if !key.activatesAt.After(now) && (key.retiresAt == nil || key.retiresAt.After(now)) {
+			candidates = append(candidates, key)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].activatesAt.After(candidates[j].activatesAt)
+	})
+	return candidates[0]
+}
+
+// generateKey creates a new key with a random kid
+func generateKey(algorithm string) (*signingKey, error) {
+	var private crypto.Signer
+	var err error
+	if algorithm == AlgRS256 {
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %v", err)
+	}
+
+	return &signingKey{
+		kid:         thumbprint(private.Public()),
+		algorithm:   algorithm,
+		private:     private,
+		activatesAt: time.Now(),
+	}, nil
+}
+
+// loadKeyFile reads a PEM private key; the kid is derived from the public key
+func loadKeyFile(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %v", path, err)
+	}
+	private, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %s: %v", path, err)
+	}
+
+	algorithm := AlgEdDSA
+	if _, ok := private.(*rsa.PrivateKey); ok {
+		algorithm = AlgRS256
+	}
+	return &signingKey{
+		kid:       thumbprint(private.Public()),
+		algorithm: algorithm,
+		private:   private,
+	}, nil
+}
+
+// parsePrivateKey decodes an RSA or Ed25519 private key from PEM
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key format")
+}
+
+// encodePrivateKey encodes a private key as PKCS#8 PEM
+func encodePrivateKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// thumbprint derives a stable kid from the public key
+func thumbprint(pub crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return fmt.Sprintf("key-%d", time.Now().UnixNano())
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
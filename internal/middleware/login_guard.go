@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"time"
+	"webcrawler-backend/internal/config"
+	"webcrawler-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Login attempt actions
+const (
+	AttemptLogin         = "login"
+	AttemptRegister      = "register"
+	AttemptPasswordReset = "password_reset"
+)
+
+// LoginPolicy configures brute-force protection for login and registration
+type LoginPolicy struct {
+	// Per account: lock the account after this many consecutive failures
+	MaxAccountFailures int
+	LockoutDuration    time.Duration
+
+	// Per IP: block logins after this many failures within IPWindow
+	MaxIPFailures int
+	IPWindow      time.Duration
+
+	// Per IP: maximum registrations within IPWindow
+	MaxIPRegistrations int
+
+	// Per IP: maximum password reset requests within IPWindow
+	MaxIPPasswordResets int
+
+	// Progressive delay: after DelayAfter failures, each further attempt must wait
+	// BaseDelay * 2^(failures-DelayAfter), capped at MaxDelay, since the last failure
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultLoginPolicy returns the default brute-force protection policy
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxAccountFailures:  5,
+		LockoutDuration:     15 * time.Minute,
+		MaxIPFailures:       20,
+		IPWindow:            15 * time.Minute,
+		MaxIPRegistrations:  10,
+		MaxIPPasswordResets: 10,
+		DelayAfter:          3,
+		BaseDelay:           time.Second,
+		MaxDelay:            30 * time.Second,
+	}
+}
+
+// LoginPolicyFromEnv returns the default policy overridden by LOGIN_* environment variables
+func LoginPolicyFromEnv() LoginPolicy {
+	p := DefaultLoginPolicy()
+	p.MaxAccountFailures = config.EnvInt("LOGIN_MAX_FAILURES", p.MaxAccountFailures)
+	p.LockoutDuration = config.EnvDuration("LOGIN_LOCKOUT_DURATION", p.LockoutDuration)
+	p.MaxIPFailures = config.EnvInt("LOGIN_IP_MAX_FAILURES", p.MaxIPFailures)
+	p.IPWindow = config.EnvDuration("LOGIN_IP_WINDOW", p.IPWindow)
+	p.MaxIPRegistrations = config.EnvInt("REGISTER_IP_MAX", p.MaxIPRegistrations)
+	p.MaxIPPasswordResets = config.EnvInt("PASSWORD_RESET_IP_MAX", p.MaxIPPasswordResets)
+	p.DelayAfter = config.EnvInt("LOGIN_DELAY_AFTER", p.DelayAfter)
+	p.BaseDelay = config.EnvDuration("LOGIN_BASE_DELAY", p.BaseDelay)
+	p.MaxDelay = config.EnvDuration("LOGIN_MAX_DELAY", p.MaxDelay)
+	return p
+}
+
+// progressiveDelay returns how long to wait after the given number of failures
+func (p LoginPolicy) progressiveDelay(failures int) time.Duration {
+	if failures < p.DelayAfter || p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.DelayAfter; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// LoginGuard tracks login and registration attempts per IP and per account
+type LoginGuard struct {
+	db     *gorm.DB
+	policy LoginPolicy
+}
+
+// NewLoginGuard creates a new login guard
+func NewLoginGuard(db *gorm.DB, policy LoginPolicy) *LoginGuard {
+	return &LoginGuard{db: db, policy: policy}
+}
+
+// Policy returns the active policy
+func (g *LoginGuard) Policy() LoginPolicy {
+	return g.policy
+}
+
+// CheckIP returns how long the client must wait before attempting the action again,
+// or zero if the attempt is allowed
+func (g *LoginGuard) CheckIP(ip string, action string) (time.Duration, error) {
+	since := time.Now().Add(-g.policy.IPWindow)
+
+	// Registrations and password resets are limited by volume, logins by failures
+	query := g.db.Model(&models.LoginAttempt{}).Where("ip_address = ? AND action = ? AND created_at > ?", ip, action, since)
+	var limit int
+	switch action {
+	case AttemptLogin:
+		query = query.Where("success = ?", false)
+		limit = g.policy.MaxIPFailures
+	case AttemptRegister:
+		limit = g.policy.MaxIPRegistrations
+	case AttemptPasswordReset:
+		limit = g.policy.MaxIPPasswordResets
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	var last models.LoginAttempt
+	if err := query.Order("created_at desc").First(&last).Error; err != nil {
+		return 0, err
+	}
+
+	// Hard limit: blocked until the window has passed
+	if limit > 0 && int(count) >= limit {
+		return time.Until(last.CreatedAt.Add(g.policy.IPWindow)), nil
+	}
+
+	if action == AttemptLogin {
+		return time.Until(last.CreatedAt.Add(g.policy.progressiveDelay(int(count)))), nil
+	}
+	return 0, nil
+}
+
+// CheckAccount returns whether the account is locked and how long the client must wait
+// before the next attempt (lockout or progressive delay)
+func (g *LoginGuard) CheckAccount(user models.User) (locked bool, wait time.Duration) {
+	now := time.Now()
+	if user.LockedUntil != nil && user.LockedUntil.After(now) {
+		return true, user.LockedUntil.Sub(now)
+	}
+
+	if user.LastFailedLoginAt != nil {
+		if wait := user.LastFailedLoginAt.Add(g.policy.progressiveDelay(user.FailedLoginCount)).Sub(now); wait > 0 {
+			return false, wait
+		}
+	}
+	return false, 0
+}
+
+// RecordFailure stores an audit record of the failure and, for a known account,
+// increments its failure counter and locks it once the threshold is reached
+func (g *LoginGuard) RecordFailure(c *gin.Context, action string, email string, user *models.User, reason string) error {
+	attempt := models.LoginAttempt{
+		Action:    action,
+		Email:     email,
+		IPAddress: c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 500),
+		Reason:    reason,
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+	if err := g.db.Create(&attempt).Error; err != nil {
+		return err
+	}
+
+	// Throttled and locked attempts don't count towards the account lockout
+	if user == nil || action != AttemptLogin || (reason != "invalid_credentials" && reason != "invalid_mfa_code") {
+		return nil
+	}
+
+	// Increment in the database and read the count back in the same transaction: the
+	// update locks the row, so concurrent failures are all counted and only one locks the account
+	now := time.Now()
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"failed_login_count":   gorm.Expr("failed_login_count + 1"),
+			"last_failed_login_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		var failures []int
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Pluck("failed_login_count", &failures).Error; err != nil {
+			return err
+		}
+		if g.policy.MaxAccountFailures <= 0 || len(failures) == 0 || failures[0] < g.policy.MaxAccountFailures {
+			return nil
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"locked_until":       now.Add(g.policy.LockoutDuration),
+			"failed_login_count": 0,
+		}).Error
+	})
+}
+
+// RecordSuccess stores a successful attempt for IP accounting and, for logins,
+// resets the account failure counter
+func (g *LoginGuard) RecordSuccess(c *gin.Context, action string, user models.User) error {
+	attempt := models.LoginAttempt{
+		Action:    action,
+		Email:     user.Email,
+		UserID:    &user.ID,
+		IPAddress: c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 500),
+		Success:   true,
+	}
+	// Only registrations are counted on success
+	if action == AttemptRegister {
+		if err := g.db.Create(&attempt).Error; err != nil {
+			return err
+		}
+	}
+
+	if action != AttemptLogin || (user.FailedLoginCount == 0 && user.LockedUntil == nil && user.LastFailedLoginAt == nil) {
+		return nil
+	}
+	return g.db.Model(&user).Updates(map[string]interface{}{
+		"failed_login_count":   0,
+		"last_failed_login_at": nil,
+		"locked_until":         nil,
+	}).Error
+}
+
+// RecordRequest stores an attempt that is limited by volume per IP, such as a password
+// reset request, whatever its outcome
+func (g *LoginGuard) RecordRequest(c *gin.Context, action string, email string) error {
+	return g.db.Create(&models.LoginAttempt{
+		Action:    action,
+		Email:     email,
+		IPAddress: c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 500),
+		Success:   true,
+	}).Error
+}
+
+// Unlock clears an account lockout and its failure counter
+func (g *LoginGuard) Unlock(userID uint) error {
+	return g.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_login_count":   0,
+		"last_failed_login_at": nil,
+		"locked_until":         nil,
+	}).Error
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
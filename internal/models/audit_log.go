@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditLogImmutable is returned when updating or deleting an audit log entry
+var ErrAuditLogImmutable = errors.New("audit log entries cannot be modified")
+
+// AuditLog is an append-only record of a security or crawl-management action
+type AuditLog struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	ActorID    *uint  `json:"actor_id" gorm:"index"` // Nil for anonymous actions (e.g. failed logins)
+	ActorEmail string `json:"actor_email" gorm:"type:varchar(255)"`
+	// Set when an admin acted while impersonating the actor
+	ImpersonatorID    *uint     `json:"impersonator_id,omitempty" gorm:"index"`
+	ImpersonatorEmail string    `json:"impersonator_email,omitempty" gorm:"type:varchar(255)"`
+	Action            string    `json:"action" gorm:"type:varchar(100);not null;index"` // e.g. auth.login, crawl.delete
+	TargetType        string    `json:"target_type" gorm:"type:varchar(50);index:idx_audit_logs_target,priority:1"`
+	TargetID          string    `json:"target_id" gorm:"type:varchar(100);index:idx_audit_logs_target,priority:2"`
+	IPAddress         string    `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent         string    `json:"user_agent" gorm:"type:varchar(500)"`
+	Before            JSON      `json:"before" gorm:"type:json"` // State before the change, if any
+	After             JSON      `json:"after" gorm:"type:json"`  // State after the change, if any
+	CreatedAt         time.Time `json:"created_at" gorm:"index"`
+}
+
+// BeforeUpdate keeps the audit log append-only
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete keeps the audit log append-only
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
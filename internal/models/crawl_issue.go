@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Issue types
+const (
+	IssueRedirectLoop      = "redirect_loop"       // Redirects come back to a URL until the crawler gives up
+	IssueLongRedirectChain = "long_redirect_chain" // More redirects than search engines and browsers like
+	IssueHTTPSDowngrade    = "https_downgrade"     // An HTTPS URL redirects to HTTP
+	IssueTemporaryRedirect = "temporary_redirect"  // A 302 or 307 to the canonical URL, which should be permanent
+)
+
+// IssueTypes lists the issue types, in the order they are reported
+var IssueTypes = []string{IssueRedirectLoop, IssueLongRedirectChain, IssueHTTPSDowngrade, IssueTemporaryRedirect}
+
+// CrawlIssue is a problem found by a crawl
+type CrawlIssue struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	CrawlResultID   uint      `json:"crawl_result_id" gorm:"not null;index:idx_issue_crawl_type,priority:1"`
+	Type            string    `json:"type" gorm:"type:varchar(50);not null;index:idx_issue_crawl_type,priority:2"`
+	URL             string    `json:"url" gorm:"type:varchar(2048);not null"`
+	Detail          string    `json:"detail" gorm:"type:text"`
+	RedirectChainID *uint     `json:"redirect_chain_id,omitempty" gorm:"index"`
+	CreatedAt       time.Time `json:"created_at"`
+}
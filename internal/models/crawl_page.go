@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// CrawlPage is one page fetched by a site crawl
+type CrawlPage struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	CrawlResultID uint      `json:"crawl_result_id" gorm:"not null;index"`
+	URL           string    `json:"url" gorm:"type:varchar(2048);not null"`
+	Depth         int       `json:"depth"` // Link hops from the seed URL
+	StatusCode    int       `json:"status_code"`
+	Title         string    `json:"title" gorm:"type:varchar(500)"`
+	InternalLinks int       `json:"internal_links"`
+	ExternalLinks int       `json:"external_links"`
+	HasLoginForm  bool      `json:"has_login_form"`
+	Forms         JSON      `json:"forms" gorm:"type:json"` // Every form with its class, see crawler.Form
+	ErrorMessage  string    `json:"error_message,omitempty" gorm:"type:text"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// FrontierURL is a URL discovered by a site crawl. Pending entries are still to be fetched;
+// visited entries make up the visited set. Persisting both lets a paused or interrupted
+// crawl resume where it stopped.
+type FrontierURL struct {
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	CrawlResultID uint   `json:"crawl_result_id" gorm:"not null;uniqueIndex:idx_frontier_crawl_url;index:idx_frontier_pending,priority:1"`
+	URLHash       string `json:"-" gorm:"type:char(64);not null;uniqueIndex:idx_frontier_crawl_url"` // SHA-256 of the URL
+	URL           string `json:"url" gorm:"type:varchar(2048);not null"`
+	Depth         int    `json:"depth"`
+	Visited       bool   `json:"visited" gorm:"default:false;index:idx_frontier_pending,priority:2"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CrawlPreset is a named set of crawl options and scope rules, owned by a user or shared
+// with an organization, that crawls can be created from
+type CrawlPreset struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	Name           string         `json:"name" gorm:"type:varchar(100);not null"`
+	Description    string         `json:"description" gorm:"type:varchar(500)"`
+	UserID         *uint          `json:"user_id" gorm:"index"`         // Owner of a personal preset
+	OrganizationID *uint          `json:"organization_id" gorm:"index"` // Owner of a shared preset
+	CreatedByID    uint           `json:"created_by_id" gorm:"not null"`
+	Options        JSON           `json:"options" gorm:"type:json"` // Options set by the preset, see crawler.Options
+	Scope          JSON           `json:"scope" gorm:"type:json"`   // See crawler.Scope
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+}
@@ -1,45 +1,125 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 	"gorm.io/gorm"
 )
 
 // CrawlStatus type and constants
-// Only allow: queued, running, done, error
+// Only allow: queued, running, paused, done, error, stopped
 //
 type CrawlStatus string
 
 const (
 	StatusQueued  CrawlStatus = "queued"
 	StatusRunning CrawlStatus = "running"
+	StatusPaused  CrawlStatus = "paused"  // Frontier kept, resumes where it stopped
 	StatusDone    CrawlStatus = "done"
 	StatusError   CrawlStatus = "error"
+	StatusStopped CrawlStatus = "stopped" // Cancelled by the user, frontier discarded
 )
 
+// CrawlPriority orders the queue: higher priorities are dequeued first.
+// The zero value is unset and stored as normal.
+type CrawlPriority int
+
+const (
+	PriorityLow    CrawlPriority = 1
+	PriorityNormal CrawlPriority = 2
+	PriorityHigh   CrawlPriority = 3
+	PriorityUrgent CrawlPriority = 4 // Reserved for admins and operators
+)
+
+var priorityNames = map[CrawlPriority]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+	PriorityUrgent: "urgent",
+}
+
+// ParsePriority returns the priority with the given name
+func ParsePriority(name string) (CrawlPriority, bool) {
+	for p, n := range priorityNames {
+		if n == name {
+			return p, true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// String returns the priority name
+func (p CrawlPriority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// MarshalJSON writes the priority name
+func (p CrawlPriority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON reads a priority name
+func (p *CrawlPriority) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	parsed, ok := ParsePriority(name)
+	if !ok {
+		return fmt.Errorf("unknown priority %q", name)
+	}
+	*p = parsed
+	return nil
+}
+
 // CrawlResult represents a single crawl result
 type CrawlResult struct {
 	ID                uint           `json:"id" gorm:"primaryKey"`
 	UserID            *uint          `json:"user_id" gorm:"index"` // Nullable for backward compatibility
 	User              *User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	OrganizationID    *uint          `json:"organization_id" gorm:"index"` // Shared with the organization's members when set
+	Organization      *Organization  `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
 	URL               string         `json:"url" gorm:"type:varchar(500);not null;index:idx_url,length:255"` // Reduced length for index compatibility
 	Title             string         `json:"title" gorm:"type:varchar(500)"`
-	HTMLVersion       string         `json:"html_version" gorm:"type:varchar(10)"`
-	Status            CrawlStatus    `json:"status" gorm:"type:enum('queued','running','done','error');default:'queued'"`
+	HTMLVersion       string         `json:"html_version" gorm:"type:varchar(30)"` // From the doctype: HTML5, HTML 4.01 Strict, XHTML 1.1, ... or unknown
+	HTMLVersionConfidence float64    `json:"html_version_confidence"`                // 0-1, lower when the version is inferred from an unusual doctype
+	DocumentMode      string         `json:"document_mode" gorm:"type:varchar(20)"`  // How browsers render the page: no-quirks, limited-quirks or quirks
+	Status            CrawlStatus    `json:"status" gorm:"type:enum('queued','running','paused','done','error','stopped');default:'queued'"`
 	Progress          int            `json:"progress"` // 0-100
+	MaxPages          int            `json:"max_pages" gorm:"default:0"` // Page budget of a site crawl, capped by the owner's quota
+	Priority          CrawlPriority  `json:"priority" gorm:"default:2;index"`
+	PagesCrawled      int            `json:"pages_crawled" gorm:"default:0"`
+	PausedAt          *time.Time     `json:"paused_at,omitempty"` // Set once a paused crawl is no longer running; resuming waits for it
+	Options           JSON           `json:"options" gorm:"type:json"`      // How pages are fetched, see crawler.Options
+	AuthType          string         `json:"auth_type" gorm:"type:varchar(20)"` // Set when the crawl logs in, see crawler.Auth
+	Credentials       []byte         `json:"-" gorm:"type:blob;serializer:encrypted"` // crawler.Auth as JSON, never returned
+	Scope             JSON           `json:"scope" gorm:"type:json"`        // Which discovered URLs are followed, see crawler.Scope
+	SkippedURLs       JSON           `json:"skipped_urls" gorm:"type:json"` // Out-of-scope URLs by reason: {"host": 12, "exclude": 3, ...}
+	IssueCounts       JSON           `json:"issue_counts" gorm:"type:json"` // Issues by type: {"https_downgrade": 2, ...}
 	HeadingCounts     JSON           `json:"heading_counts" gorm:"type:json"` // Store as JSON: {"h1": 2, "h2": 5, ...}
 	InternalLinks     int            `json:"internal_links" gorm:"default:0"`
 	ExternalLinks     int            `json:"external_links" gorm:"default:0"`
 	InaccessibleLinks int            `json:"inaccessible_links" gorm:"default:0"`
-	HasLoginForm      bool           `json:"has_login_form" gorm:"default:false"`
+	HasLoginForm      bool           `json:"has_login_form" gorm:"default:false"` // A crawled page has a form with a password field
+	HasClassifiedLoginForm bool      `json:"has_classified_login_form" gorm:"default:false"` // A crawled page has a form classified as login
+	HasSignupForm     bool           `json:"has_signup_form" gorm:"default:false"` // Set when a crawled page has a form of the class, see FormClasses
+	HasPasswordResetForm bool        `json:"has_password_reset_form" gorm:"default:false"`
+	HasSearchForm     bool           `json:"has_search_form" gorm:"default:false"`
+	HasNewsletterForm bool           `json:"has_newsletter_form" gorm:"default:false"`
+	HasPaymentForm    bool           `json:"has_payment_form" gorm:"default:false"`
+	HasContactForm    bool           `json:"has_contact_form" gorm:"default:false"`
 	ErrorMessage      string         `json:"error_message" gorm:"type:text"`
     CreatedAt         time.Time      `json:"created_at" gorm:"autoCreateTime"`
     UpdatedAt         time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt         gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 	
 	// Relationships
-	BrokenLinks []BrokenLink `json:"broken_links,omitempty" gorm:"foreignKey:CrawlResultID"`
+	BrokenLinks []BrokenLink    `json:"broken_links,omitempty" gorm:"foreignKey:CrawlResultID"`
+	Redirects   []RedirectChain `json:"redirects,omitempty" gorm:"foreignKey:CrawlResultID"`
 }
 
 // JSON is a custom type for JSON fields
@@ -75,4 +155,21 @@ func (j JSON) IsNull() bool {
 // String returns the JSON as a string
 func (j JSON) String() string {
 	return string(j)
-} 
\ No newline at end of file
+}
+
+// MarshalJSON writes the stored document as-is instead of base64
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if j.IsNull() {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON stores a copy of the document as-is, the inverse of MarshalJSON
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	if j == nil {
+		return fmt.Errorf("models.JSON: UnmarshalJSON on nil pointer")
+	}
+	*j = append((*j)[0:0], data...)
+	return nil
+}
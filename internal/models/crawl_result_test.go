@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	type document struct {
+		Counts JSON `json:"counts"`
+	}
+	tests := []struct {
+		name string
+		in   JSON
+		want string
+	}{
+		{"object", JSON(`{"h1":2,"h2":5}`), `{"counts":{"h1":2,"h2":5}}`},
+		{"array", JSON(`[1,2]`), `{"counts":[1,2]}`},
+		{"empty", nil, `{"counts":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(document{Counts: tt.in})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Fatalf("Marshal() = %s, want %s", data, tt.want)
+			}
+
+			var decoded document
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			again, _ := json.Marshal(decoded)
+			if string(again) != tt.want {
+				t.Errorf("round trip = %s, want %s", again, tt.want)
+			}
+			if decoded.Counts.IsNull() != tt.in.IsNull() {
+				t.Errorf("IsNull() = %v after round trip, want %v", decoded.Counts.IsNull(), tt.in.IsNull())
+			}
+		})
+	}
+}
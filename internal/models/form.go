@@ -0,0 +1,26 @@
+package models
+
+// Form classes, see crawler.classifyForm
+const (
+	FormLogin         = "login"
+	FormSignup        = "signup"
+	FormPasswordReset = "password_reset"
+	FormSearch        = "search"
+	FormNewsletter    = "newsletter"
+	FormPayment       = "payment"
+	FormContact       = "contact"
+	FormOther         = "other" // None of the above
+)
+
+// FormClasses lists the classes crawls are filtered by
+var FormClasses = []string{FormLogin, FormSignup, FormPasswordReset, FormSearch, FormNewsletter, FormPayment, FormContact}
+
+// FormColumn returns the crawl_results column telling whether a crawl found a form of the class.
+// has_login_form keeps its original meaning (a form with a password field), so forms classified
+// as login have their own column.
+func FormColumn(class string) string {
+	if class == FormLogin {
+		return "has_classified_login_form"
+	}
+	return "has_" + class + "_form"
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// NetworkAllowlistEntry lets crawls reach an internal network or host (CIDR, IP address or host name)
+// that the SSRF protection blocks otherwise
+type NetworkAllowlistEntry struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Value       string    `json:"value" gorm:"type:varchar(255);not null;uniqueIndex"`
+	Note        string    `json:"note" gorm:"type:varchar(255)"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
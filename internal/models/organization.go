@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrgRole type and constants
+// Only allow: owner, editor, viewer
+type OrgRole string
+
+const (
+	OrgRoleOwner  OrgRole = "owner"  // Manage members, settings and crawls
+	OrgRoleEditor OrgRole = "editor" // Create, run, stop and delete crawls
+	OrgRoleViewer OrgRole = "viewer" // Read-only access to crawls
+)
+
+// IsValid reports whether the role is one of the known roles
+func (r OrgRole) IsValid() bool {
+	return r == OrgRoleOwner || r == OrgRoleEditor || r == OrgRoleViewer
+}
+
+// CanEdit reports whether the role may create and manage crawls
+func (r OrgRole) CanEdit() bool {
+	return r == OrgRoleOwner || r == OrgRoleEditor
+}
+
+// Organization is a team sharing crawls
+type Organization struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"type:varchar(255);not null"`
+	CreatedByID uint           `json:"created_by_id" gorm:"not null;index"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	// Relationships
+	Memberships []Membership `json:"memberships,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+// Membership links a user to an organization with a role
+type Membership struct {
+	ID             uint          `json:"id" gorm:"primaryKey"`
+	OrganizationID uint          `json:"organization_id" gorm:"not null;uniqueIndex:idx_membership_org_user"`
+	Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	UserID         uint          `json:"user_id" gorm:"not null;uniqueIndex:idx_membership_org_user;index"`
+	User           *User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Role           OrgRole       `json:"role" gorm:"type:enum('owner','editor','viewer');default:'viewer'"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxMessage is an email captured by the database outbox mailer (development and tests)
+type OutboxMessage struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	From      string    `json:"from" gorm:"type:varchar(255);not null"`
+	To        string    `json:"to" gorm:"type:varchar(255);not null;index"`
+	Subject   string    `json:"subject" gorm:"type:varchar(255);not null"`
+	Body      string    `json:"body" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
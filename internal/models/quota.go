@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Quota scopes, from most to least specific
+const (
+	QuotaScopeUser         = "user"
+	QuotaScopeOrganization = "organization"
+	QuotaScopeRole         = "role"
+)
+
+// QuotaLimit overrides the default crawl quotas for a user, organization or role.
+// Nil limits are inherited from the next scope; 0 means unlimited.
+type QuotaLimit struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Scope            string    `json:"scope" gorm:"type:varchar(20);not null;uniqueIndex:idx_quota_scope_subject"`    // user, organization, role
+	Subject          string    `json:"subject" gorm:"type:varchar(100);not null;uniqueIndex:idx_quota_scope_subject"` // User ID, organization ID or role name
+	MaxQueued        *int      `json:"max_queued"`
+	CrawlsPerDay     *int      `json:"crawls_per_day"`
+	MaxPagesPerCrawl *int      `json:"max_pages_per_crawl"`
+	MaxRunning       *int      `json:"max_running"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
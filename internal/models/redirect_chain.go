@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Sources of a redirect chain
+const (
+	RedirectSourcePage = "page" // A page fetched by the crawl, the seed included
+	RedirectSourceLink = "link" // A link checked by the crawl
+)
+
+// RedirectChain is the redirects followed from a crawled page or a checked link
+type RedirectChain struct {
+	ID            uint         `json:"id" gorm:"primaryKey"`
+	CrawlResultID uint         `json:"crawl_result_id" gorm:"not null;index"`
+	Source        string       `json:"source" gorm:"type:varchar(10);not null"` // page or link
+	URL           string       `json:"url" gorm:"type:varchar(2048);not null"`
+	FinalURL      string       `json:"final_url" gorm:"type:varchar(2048)"`
+	FinalStatus   int          `json:"final_status"`          // 0 when the chain did not end with a response
+	HopCount      int          `json:"hop_count"`             // Redirects in the chain
+	Hops          JSON         `json:"hops" gorm:"type:json"` // Every response: [{"url", "status_code", "location", "duration_ms"}]
+	CreatedAt     time.Time    `json:"created_at"`
+	Issues        []CrawlIssue `json:"issues,omitempty" gorm:"foreignKey:RedirectChainID"`
+}
@@ -5,16 +5,48 @@ import (
 	"gorm.io/gorm"
 )
 
+// Global roles, from most to least privileged
+const (
+	RoleAdmin    = "admin"    // Everything, including user administration
+	RoleOperator = "operator" // Manage the queue and stop any crawl, no user administration
+	RoleUser     = "user"     // Manage own and organization crawls
+	RoleViewer   = "viewer"   // Read-only access to own and organization crawls and stats
+)
+
+// Roles lists the valid roles from most to least privileged
+var Roles = []string{RoleAdmin, RoleOperator, RoleUser, RoleViewer}
+
+// IsValidRole reports whether the role is one of the known roles
+func IsValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 // User represents a user in the system
 type User struct {
 	ID            uint           `json:"id" gorm:"primaryKey"`
 	Email         string         `json:"email" gorm:"type:varchar(255);unique;not null;index"`
 	PasswordHash  string         `json:"-" gorm:"type:varchar(255);not null"` // "-" means don't include in JSON
 	Name          string         `json:"name" gorm:"type:varchar(255);not null"`
-	Role          string         `json:"role" gorm:"type:enum('admin','user');default:'user';index"`
+	Role          string         `json:"role" gorm:"type:enum('admin','operator','user','viewer');default:'user';index"`
 	IsActive      bool           `json:"is_active" gorm:"default:true;index"`
 	EmailVerified bool           `json:"email_verified" gorm:"default:false"`
+	VerificationSentAt *time.Time `json:"-"` // Last verification email, used to rate limit resends
+	PasswordResetSentAt *time.Time `json:"-"` // Last password reset email, used to rate limit requests
 	LastLogin     *time.Time     `json:"last_login"`
+	PasswordChangedAt *time.Time `json:"-"` // Access tokens issued before this are rejected
+	FailedLoginCount  int        `json:"failed_login_count" gorm:"default:0"` // Consecutive failed logins
+	LastFailedLoginAt *time.Time `json:"last_failed_login_at"`
+	LockedUntil       *time.Time `json:"locked_until" gorm:"index"` // Temporary lockout after too many failures
+	MFAEnabled        bool       `json:"mfa_enabled" gorm:"default:false"`
+	MFASecret         string     `json:"-" gorm:"type:varchar(255);serializer:encrypted"` // Base32 TOTP secret, pending until MFAEnabled
+	MFALastStep       int64      `json:"-" gorm:"default:0"`        // Last accepted TOTP time step, prevents code replay
+	AuthProvider      string     `json:"auth_provider" gorm:"type:varchar(50);default:'local'"` // local, oidc
+	ExternalSubject   string     `json:"-" gorm:"type:varchar(255);index"`                       // IdP subject (issuer|sub) for SSO users
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
@@ -23,6 +55,7 @@ type User struct {
 	CrawlResults []CrawlResult `json:"crawl_results,omitempty" gorm:"foreignKey:UserID"`
 	RefreshTokens []RefreshToken `json:"-" gorm:"foreignKey:UserID"`
 	APIKeys      []APIKey       `json:"-" gorm:"foreignKey:UserID"`
+	RecoveryCodes []RecoveryCode `json:"-" gorm:"foreignKey:UserID"`
 }
 
 // RefreshToken represents a JWT refresh token
@@ -49,4 +82,58 @@ type APIKey struct {
 	ExpiresAt   *time.Time     `json:"expires_at" gorm:"index"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
-} 
\ No newline at end of file
+} 
+
+// PasswordResetToken represents a single-use password reset token sent by email
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      User       `json:"user" gorm:"foreignKey:UserID"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"` // SHA-256 of the token
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginAttempt is an audit record of a failed login, a registration attempt or a password reset request
+type LoginAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Action    string    `json:"action" gorm:"type:varchar(20);not null;index:idx_login_attempts_ip,priority:2"` // login, register, password_reset
+	Email     string    `json:"email" gorm:"type:varchar(255);index"`
+	UserID    *uint     `json:"user_id" gorm:"index"`
+	IPAddress string    `json:"ip_address" gorm:"type:varchar(45);not null;index:idx_login_attempts_ip,priority:1"`
+	UserAgent string    `json:"user_agent" gorm:"type:varchar(500)"`
+	Success   bool      `json:"success" gorm:"default:false"`
+	Reason    string    `json:"reason" gorm:"type:varchar(100)"` // invalid_credentials, locked, throttled, ...
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_login_attempts_ip,priority:3"`
+}
+
+// RecoveryCode is a single-use MFA backup code
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64);not null;index"` // SHA-256 of the code
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OIDCLoginState holds the per-login secrets of an OpenID Connect authorization request
+type OIDCLoginState struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	State        string    `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Nonce        string    `json:"-" gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `json:"-" gorm:"type:varchar(128);not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SigningKey is a database-managed JWT signing key, rotated on schedule
+type SigningKey struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	KID           string     `json:"kid" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Algorithm     string     `json:"algorithm" gorm:"type:varchar(10);not null"`
+	PrivateKeyPEM string     `json:"-" gorm:"type:text;not null;serializer:encrypted"`
+	ActivatesAt   time.Time  `json:"activates_at"`               // Published before, signs from this time
+	RetiresAt     *time.Time `json:"retires_at" gorm:"index"`    // No longer accepted after this time
+	CreatedAt     time.Time  `json:"created_at"`
+}
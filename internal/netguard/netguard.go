@@ -0,0 +1,231 @@
+// Package netguard keeps server-side requests to user-submitted URLs away from internal networks.
+package netguard
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// blockedRanges are never reachable unless allowlisted: loopback, private, link-local (including
+// cloud metadata endpoints), shared, multicast and reserved ranges
+var blockedRanges = mustPrefixes(
+	"0.0.0.0/8",       // "This" network
+	"10.0.0.0/8",      // Private
+	"100.64.0.0/10",   // Carrier-grade NAT
+	"127.0.0.0/8",     // Loopback
+	"169.254.0.0/16",  // Link-local, e.g. 169.254.169.254 metadata
+	"172.16.0.0/12",   // Private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // Documentation
+	"192.168.0.0/16",  // Private
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // Documentation
+	"203.0.113.0/24",  // Documentation
+	"224.0.0.0/4",     // Multicast
+	"240.0.0.0/4",     // Reserved, including broadcast
+	"::/128",          // Unspecified
+	"::1/128",         // Loopback
+	"64:ff9b:1::/48",  // Local-use NAT64
+	"100::/64",        // Discard
+	"2001:db8::/32",   // Documentation
+	"fc00::/7",        // Unique local, e.g. fd00:ec2::254 metadata
+	"fe80::/10",       // Link-local
+	"ff00::/8",        // Multicast
+)
+
+// nat64 embeds IPv4 addresses in IPv6, which must be checked as IPv4
+var nat64 = netip.MustParsePrefix("64:ff9b::/96")
+
+// BlockedError is returned when a request would reach a blocked address
+type BlockedError struct {
+	Host string
+	IP   netip.Addr
+}
+
+func (e *BlockedError) Error() string {
+	if e.Host != "" && e.Host != e.IP.String() {
+		return fmt.Sprintf("%s resolves to %s, an internal address that may not be crawled", e.Host, e.IP)
+	}
+	return fmt.Sprintf("%s is an internal address that may not be crawled", e.IP)
+}
+
+// Allowlist lists the internal networks and hosts that may be crawled, e.g. for intranet sites
+type Allowlist struct {
+	Prefixes []netip.Prefix
+	Hosts    []string // Exact host names, or "*.example.com" for subdomains
+}
+
+// ParseAllowlistEntry parses a CIDR, an IP address or a host name
+func ParseAllowlistEntry(entry string, list *Allowlist) error {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		list.Prefixes = append(list.Prefixes, prefix.Masked())
+		return nil
+	}
+	if addr, err := netip.ParseAddr(entry); err == nil {
+		list.Prefixes = append(list.Prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		return nil
+	}
+	name := strings.TrimPrefix(entry, "*.")
+	if name == "" || strings.ContainsAny(name, "/:*?#@ ") {
+		return fmt.Errorf("%q is not a CIDR, IP address or host name", entry)
+	}
+	list.Hosts = append(list.Hosts, entry)
+	return nil
+}
+
+// Guard checks the addresses outgoing connections go to
+type Guard struct {
+	enabled bool
+
+	mu        sync.RWMutex
+	base      Allowlist // From SSRF_ALLOWLIST
+	allowlist Allowlist // Base plus the entries managed by admins
+}
+
+// NewGuard creates a guard; a disabled guard allows every address
+func NewGuard(enabled bool, base Allowlist) *Guard {
+	return &Guard{enabled: enabled, base: base, allowlist: base}
+}
+
+// GuardFromEnv creates a guard from SSRF_PROTECTION (on unless "false") and SSRF_ALLOWLIST
+// (comma-separated CIDRs, IP addresses and host names)
+func GuardFromEnv() (*Guard, error) {
+	var base Allowlist
+	for _, entry := range strings.Split(os.Getenv("SSRF_ALLOWLIST"), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		if err := ParseAllowlistEntry(entry, &base); err != nil {
+			return nil, fmt.Errorf("SSRF_ALLOWLIST: %v", err)
+		}
+	}
+	return NewGuard(os.Getenv("SSRF_PROTECTION") != "false", base), nil
+}
+
+// SetAllowlist replaces the admin-managed entries
+func (g *Guard) SetAllowlist(entries Allowlist) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.allowlist = Allowlist{
+		Prefixes: append(append([]netip.Prefix{}, g.base.Prefixes...), entries.Prefixes...),
+		Hosts:    append(append([]string{}, g.base.Hosts...), entries.Hosts...),
+	}
+}
+
+// Enabled reports whether the guard blocks internal addresses
+func (g *Guard) Enabled() bool {
+	return g.enabled
+}
+
+// CheckIP returns a BlockedError when ip may not be reached
+func (g *Guard) CheckIP(host string, ip netip.Addr) error {
+	if !g.enabled {
+		return nil
+	}
+	ip = ip.Unmap().WithZone("")
+	if nat64.Contains(ip) {
+		embedded := ip.As16()
+		ip = netip.AddrFrom4([4]byte{embedded[12], embedded[13], embedded[14], embedded[15]})
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	for _, prefix := range g.allowlist.Prefixes {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+	for _, prefix := range blockedRanges {
+		if prefix.Contains(ip) {
+			return &BlockedError{Host: host, IP: ip}
+		}
+	}
+	return nil
+}
+
+// hostAllowed reports whether a host name is allowlisted, in which case it may resolve anywhere
+func (g *Guard) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	for _, allowed := range g.allowlist.Hosts {
+		if wildcard := strings.TrimPrefix(allowed, "*."); wildcard != allowed {
+			if strings.HasSuffix(host, "."+wildcard) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL resolves the host of a URL and returns an error when any of its addresses is blocked.
+// It gives early feedback on submitted URLs; the dialer checks again on every connection.
+func (g *Guard) CheckURL(ctx context.Context, u *url.URL) error {
+	if !g.enabled || g.hostAllowed(u.Hostname()) {
+		return nil
+	}
+	host := u.Hostname()
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return g.CheckIP(host, ip)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil // Unresolvable hosts fail when crawled, like any broken site
+	}
+	for _, ip := range addrs {
+		if err := g.CheckIP(host, ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DialContext connects like net.Dialer but refuses blocked addresses. The check runs on the
+// address actually connected to, after DNS resolution, so DNS rebinding and redirects to
+// internal addresses are caught as well.
+func (g *Guard) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if g.enabled && !g.hostAllowed(host) {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return g.CheckIP(host, addrPort.Addr())
+		}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// Transport returns an HTTP transport whose connections go through the guard. Proxies from
+// the environment are not used, since the guard could not see where they connect.
+func (g *Guard) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = g.DialContext
+	return transport
+}
+
+func mustPrefixes(cidrs ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	for i, cidr := range cidrs {
+		prefixes[i] = netip.MustParsePrefix(cidr)
+	}
+	return prefixes
+}
@@ -0,0 +1,133 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestCheckIP(t *testing.T) {
+	var allowlist Allowlist
+	for _, entry := range []string{"10.1.0.0/16", "fd00::1"} {
+		if err := ParseAllowlistEntry(entry, &allowlist); err != nil {
+			t.Fatal(err)
+		}
+	}
+	guard := NewGuard(true, allowlist)
+
+	tests := []struct {
+		name    string
+		ip      string
+		blocked bool
+	}{
+		{"public IPv4", "93.184.216.34", false},
+		{"public IPv6", "2606:2800:220:1:248:1893:25c8:1946", false},
+		{"loopback", "127.0.0.1", true},
+		{"loopback range", "127.10.0.1", true},
+		{"IPv6 loopback", "::1", true},
+		{"unspecified", "0.0.0.0", true},
+		{"RFC 1918 10/8", "10.0.0.1", true},
+		{"RFC 1918 172.16/12", "172.31.255.255", true},
+		{"RFC 1918 192.168/16", "192.168.1.1", true},
+		{"just outside 172.16/12", "172.32.0.1", false},
+		{"carrier-grade NAT", "100.64.0.1", true},
+		{"link-local", "169.254.1.1", true},
+		{"cloud metadata", "169.254.169.254", true},
+		{"IPv6 link-local", "fe80::1", true},
+		{"IPv6 link-local with zone", "fe80::1%eth0", true},
+		{"unique local metadata", "fd00:ec2::254", true},
+		{"multicast", "224.0.0.1", true},
+		{"broadcast", "255.255.255.255", true},
+		{"IPv4-mapped loopback", "::ffff:127.0.0.1", true},
+		{"IPv4-mapped metadata", "::ffff:169.254.169.254", true},
+		{"IPv4-mapped public", "::ffff:93.184.216.34", false},
+		{"NAT64 loopback", "64:ff9b::7f00:1", true},
+		{"NAT64 metadata", "64:ff9b::a9fe:a9fe", true},
+		{"NAT64 public", "64:ff9b::5db8:d822", false},
+		{"allowlisted private network", "10.1.2.3", false},
+		{"private address outside the allowlisted network", "10.2.0.1", true},
+		{"IPv4-mapped allowlisted address", "::ffff:10.1.2.3", false},
+		{"allowlisted IPv6 address", "fd00::1", false},
+		{"IPv6 address next to the allowlisted one", "fd00::2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.CheckIP("", netip.MustParseAddr(tt.ip))
+			var blocked *BlockedError
+			if got := errors.As(err, &blocked); got != tt.blocked {
+				t.Errorf("CheckIP(%s) = %v, want blocked %v", tt.ip, err, tt.blocked)
+			}
+		})
+	}
+
+	t.Run("disabled guard", func(t *testing.T) {
+		if err := NewGuard(false, Allowlist{}).CheckIP("", netip.MustParseAddr("169.254.169.254")); err != nil {
+			t.Errorf("CheckIP() = %v, want nil", err)
+		}
+	})
+
+	t.Run("admin allowlist", func(t *testing.T) {
+		guard := NewGuard(true, Allowlist{})
+		ip := netip.MustParseAddr("192.168.10.5")
+		if guard.CheckIP("", ip) == nil {
+			t.Fatal("CheckIP() allowed a private address before it was allowlisted")
+		}
+		guard.SetAllowlist(Allowlist{Prefixes: []netip.Prefix{netip.MustParsePrefix("192.168.10.0/24")}})
+		if err := guard.CheckIP("", ip); err != nil {
+			t.Errorf("CheckIP() = %v after allowlisting, want nil", err)
+		}
+	})
+}
+
+func TestDialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	// localhost resolves to the loopback address the server listens on
+	address := net.JoinHostPort("localhost", u.Port())
+
+	tests := []struct {
+		name    string
+		guard   *Guard
+		blocked bool
+	}{
+		{"hostname resolving to a loopback address", NewGuard(true, Allowlist{}), true},
+		{"allowlisted hostname", NewGuard(true, Allowlist{Hosts: []string{"localhost"}}), false},
+		{"allowlisted network", NewGuard(true, Allowlist{Prefixes: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}}), false},
+		{"disabled guard", NewGuard(false, Allowlist{}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.guard.DialContext(context.Background(), "tcp", address)
+			if conn != nil {
+				conn.Close()
+			}
+			var blocked *BlockedError
+			if got := errors.As(err, &blocked); got != tt.blocked {
+				t.Fatalf("DialContext() = %v, want blocked %v", err, tt.blocked)
+			}
+			if !tt.blocked && err != nil {
+				t.Fatalf("DialContext() = %v, want a connection", err)
+			}
+		})
+	}
+
+	t.Run("redirect to an internal address", func(t *testing.T) {
+		redirect := httptest.NewServer(http.RedirectHandler("http://"+address+"/", http.StatusFound))
+		defer redirect.Close()
+		// The first server is allowlisted by address, the redirect target by nothing
+		target, _ := url.Parse(redirect.URL)
+		guard := NewGuard(true, Allowlist{Hosts: []string{target.Hostname()}})
+		client := &http.Client{Transport: guard.Transport()}
+		_, err := client.Get(redirect.URL)
+		var blocked *BlockedError
+		if !errors.As(err, &blocked) {
+			t.Errorf("Get() = %v, want the redirect blocked", err)
+		}
+	})
+}
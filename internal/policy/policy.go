@@ -0,0 +1,178 @@
+// Package policy defines who may do what with crawl resources. Every crawl handler
+// asks Authorize before acting, and list queries are restricted with ScopeCrawls,
+// so the access rules live in one place.
+package policy
+
+import (
+	"errors"
+	"webcrawler-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Action is an operation on a crawl
+type Action string
+
+const (
+	ActionRead       Action = "read"       // View the crawl, its results and broken links
+	ActionCreate     Action = "create"     // Create a crawl (in an organization when set)
+	ActionProcess    Action = "process"    // Run or re-run the crawl
+	ActionStop       Action = "stop"       // Stop a running crawl
+	ActionDelete     Action = "delete"     // Delete the crawl
+	ActionPrioritize Action = "prioritize" // Change the queue priority, or create urgent crawls
+)
+
+var (
+	// ErrNotFound means the subject may not even know the resource exists
+	ErrNotFound = errors.New("resource not found")
+	// ErrForbidden means the subject can see the resource but not perform the action
+	ErrForbidden = errors.New("not authorized")
+)
+
+// Subject is the user performing an action, with their organization roles
+type Subject struct {
+	UserID      uint
+	Role        string
+	Memberships map[uint]models.OrgRole // organization ID -> role
+}
+
+// LoadSubject loads the user's organization memberships
+func LoadSubject(db *gorm.DB, userID uint, role string) (Subject, error) {
+	var memberships []models.Membership
+	if err := db.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		return Subject{}, err
+	}
+
+	subject := Subject{
+		UserID:      userID,
+		Role:        role,
+		Memberships: make(map[uint]models.OrgRole, len(memberships)),
+	}
+	for _, m := range memberships {
+		subject.Memberships[m.OrganizationID] = m.Role
+	}
+	return subject, nil
+}
+
+// IsAdmin reports whether the subject has the global admin role
+func (s Subject) IsAdmin() bool {
+	return s.Role == models.RoleAdmin
+}
+
+// RolePermissions are the crawl actions a global role allows
+type RolePermissions struct {
+	Any []Action // On every crawl
+	Own []Action // On crawls the user created or shares through an organization, and on new crawls
+}
+
+func (p RolePermissions) allowsAny(action Action) bool { return containsAction(p.Any, action) }
+func (p RolePermissions) allowsOwn(action Action) bool { return containsAction(p.Own, action) }
+
+var (
+	manageActions = []Action{ActionRead, ActionCreate, ActionProcess, ActionStop, ActionDelete}
+	allActions    = append(manageActions[:len(manageActions):len(manageActions)], ActionPrioritize)
+)
+
+// Matrix is the permission matrix of global roles. Organization roles further
+// restrict the Own actions on organization crawls (viewers may only read).
+var Matrix = map[string]RolePermissions{
+	models.RoleAdmin:    {Any: allActions, Own: allActions},
+	models.RoleOperator: {Any: []Action{ActionRead, ActionProcess, ActionStop, ActionPrioritize}, Own: manageActions},
+	models.RoleUser:     {Own: manageActions},
+	models.RoleViewer:   {Own: []Action{ActionRead}},
+}
+
+// RolesAllowed returns the global roles that may perform the action on at least some crawls,
+// for route-level role checks
+func RolesAllowed(action Action) []string {
+	var roles []string
+	for _, role := range models.Roles {
+		if p := Matrix[role]; p.allowsAny(action) || p.allowsOwn(action) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// CrawlResource is the ownership information of a crawl that access rules depend on
+type CrawlResource struct {
+	OwnerID        *uint
+	OrganizationID *uint
+}
+
+// Crawl returns the resource of an existing crawl
+func Crawl(crawl models.CrawlResult) CrawlResource {
+	return CrawlResource{OwnerID: crawl.UserID, OrganizationID: crawl.OrganizationID}
+}
+
+// NewCrawl returns the resource of a crawl about to be created, optionally in an organization
+func NewCrawl(organizationID *uint) CrawlResource {
+	return CrawlResource{OrganizationID: organizationID}
+}
+
+// Authorize returns nil when the subject may perform the action on the crawl,
+// ErrNotFound when the crawl is not visible to them, and ErrForbidden otherwise.
+//
+// Rules:
+//   - the role's Any actions are allowed on every crawl (see Matrix)
+//   - the role's Own actions are allowed on crawls the subject created
+//   - on organization crawls, organization viewers may only read, editors and owners
+//     may also create, process, stop and delete
+func Authorize(subject Subject, action Action, resource CrawlResource) error {
+	permissions := Matrix[subject.Role]
+	if permissions.allowsAny(action) {
+		return nil
+	}
+
+	if action == ActionCreate {
+		if !permissions.allowsOwn(action) {
+			return ErrForbidden
+		}
+		if resource.OrganizationID == nil || subject.Memberships[*resource.OrganizationID].CanEdit() {
+			return nil
+		}
+		return ErrForbidden
+	}
+
+	// Crawls the subject can see at all
+	visible := permissions.allowsAny(ActionRead)
+	owner := resource.OwnerID != nil && *resource.OwnerID == subject.UserID
+	var orgRole models.OrgRole
+	member := false
+	if resource.OrganizationID != nil {
+		orgRole, member = subject.Memberships[*resource.OrganizationID]
+	}
+	if !visible && !owner && !member {
+		return ErrNotFound
+	}
+
+	if permissions.allowsOwn(action) && (owner || (member && (action == ActionRead || orgRole.CanEdit()))) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// ScopeCrawls restricts a query on crawl_results to the crawls the subject may read
+func ScopeCrawls(subject Subject, query *gorm.DB) *gorm.DB {
+	if Matrix[subject.Role].allowsAny(ActionRead) {
+		return query
+	}
+	if len(subject.Memberships) == 0 {
+		return query.Where("crawl_results.user_id = ?", subject.UserID)
+	}
+
+	orgIDs := make([]uint, 0, len(subject.Memberships))
+	for id := range subject.Memberships {
+		orgIDs = append(orgIDs, id)
+	}
+	return query.Where("(crawl_results.user_id = ? OR crawl_results.organization_id IN ?)", subject.UserID, orgIDs)
+}
+
+func containsAction(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
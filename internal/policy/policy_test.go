@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"webcrawler-backend/internal/models"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func uintPtr(v uint) *uint { return &v }
+
+func TestAuthorize(t *testing.T) {
+	const (
+		userID   uint = 1
+		otherID  uint = 2
+		orgID    uint = 10
+		otherOrg uint = 11
+	)
+	user := Subject{UserID: userID, Role: models.RoleUser}
+	admin := Subject{UserID: userID, Role: models.RoleAdmin}
+	orgOwner := Subject{UserID: userID, Role: models.RoleUser, Memberships: map[uint]models.OrgRole{orgID: models.OrgRoleOwner}}
+	orgViewer := Subject{UserID: userID, Role: models.RoleUser, Memberships: map[uint]models.OrgRole{orgID: models.OrgRoleViewer}}
+	viewer := Subject{UserID: userID, Role: models.RoleViewer, Memberships: map[uint]models.OrgRole{orgID: models.OrgRoleEditor}}
+
+	own := CrawlResource{OwnerID: uintPtr(userID)}
+	others := CrawlResource{OwnerID: uintPtr(otherID)}
+	orgCrawl := CrawlResource{OwnerID: uintPtr(otherID), OrganizationID: uintPtr(orgID)}
+	otherOrgCrawl := CrawlResource{OwnerID: uintPtr(otherID), OrganizationID: uintPtr(otherOrg)}
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		action   Action
+		resource CrawlResource
+		want     error
+	}{
+		{"user reads own crawl", user, ActionRead, own, nil},
+		{"user deletes own crawl", user, ActionDelete, own, nil},
+		{"user reads another user's crawl", user, ActionRead, others, ErrNotFound},
+		{"user deletes another user's crawl", user, ActionDelete, others, ErrNotFound},
+		{"user creates a crawl", user, ActionCreate, NewCrawl(nil), nil},
+		{"user creates a crawl in an organization they are not in", user, ActionCreate, NewCrawl(uintPtr(orgID)), ErrForbidden},
+		{"user prioritizes own crawl", user, ActionPrioritize, own, ErrForbidden},
+
+		{"admin reads another user's crawl", admin, ActionRead, others, nil},
+		{"admin deletes another user's crawl", admin, ActionDelete, others, nil},
+		{"admin prioritizes another user's crawl", admin, ActionPrioritize, others, nil},
+		{"admin creates in any organization", admin, ActionCreate, NewCrawl(uintPtr(otherOrg)), nil},
+
+		{"organization owner reads an organization crawl", orgOwner, ActionRead, orgCrawl, nil},
+		{"organization owner processes an organization crawl", orgOwner, ActionProcess, orgCrawl, nil},
+		{"organization owner deletes an organization crawl", orgOwner, ActionDelete, orgCrawl, nil},
+		{"organization owner creates in the organization", orgOwner, ActionCreate, NewCrawl(uintPtr(orgID)), nil},
+		{"organization owner reads another organization's crawl", orgOwner, ActionRead, otherOrgCrawl, ErrNotFound},
+
+		{"organization viewer reads an organization crawl", orgViewer, ActionRead, orgCrawl, nil},
+		{"organization viewer stops an organization crawl", orgViewer, ActionStop, orgCrawl, ErrForbidden},
+		{"organization viewer creates in the organization", orgViewer, ActionCreate, NewCrawl(uintPtr(orgID)), ErrForbidden},
+
+		{"viewer reads own crawl", viewer, ActionRead, own, nil},
+		{"viewer reads an organization crawl", viewer, ActionRead, orgCrawl, nil},
+		{"viewer reads another user's crawl", viewer, ActionRead, others, ErrNotFound},
+		{"viewer reads another organization's crawl", viewer, ActionRead, otherOrgCrawl, ErrNotFound},
+		{"viewer stops an organization crawl they could edit", viewer, ActionStop, orgCrawl, ErrForbidden},
+		{"viewer creates a crawl", viewer, ActionCreate, NewCrawl(nil), ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Authorize(tt.subject, tt.action, tt.resource); !errors.Is(err, tt.want) {
+				t.Errorf("Authorize() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// dryRunDB builds SQL without connecting to a database
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test@/test", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("open dry run database: %v", err)
+	}
+	return db
+}
+
+func TestScopeCrawls(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject Subject
+		want    string // Expected WHERE clause, empty for none
+	}{
+		{"admin sees every crawl", Subject{UserID: 1, Role: models.RoleAdmin}, ""},
+		{"user sees own crawls", Subject{UserID: 1, Role: models.RoleUser}, "WHERE crawl_results.user_id = ?"},
+		{"viewer sees own crawls", Subject{UserID: 1, Role: models.RoleViewer}, "WHERE crawl_results.user_id = ?"},
+		{
+			"viewer sees own and organization crawls",
+			Subject{UserID: 1, Role: models.RoleViewer, Memberships: map[uint]models.OrgRole{10: models.OrgRoleViewer}},
+			"WHERE (crawl_results.user_id = ? OR crawl_results.organization_id IN (?))",
+		},
+		{
+			"organization owner sees own and organization crawls",
+			Subject{UserID: 1, Role: models.RoleUser, Memberships: map[uint]models.OrgRole{10: models.OrgRoleOwner}},
+			"WHERE (crawl_results.user_id = ? OR crawl_results.organization_id IN (?))",
+		},
+	}
+	db := dryRunDB(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []map[string]interface{}
+			stmt := ScopeCrawls(tt.subject, db.Table("crawl_results")).Find(&rows).Statement
+			sql := stmt.SQL.String()
+			if stmt.Error != nil || !strings.HasPrefix(sql, "SELECT") {
+				t.Fatalf("build query: %q, %v", sql, stmt.Error)
+			}
+			_, where, found := strings.Cut(sql, " WHERE ")
+			switch {
+			case tt.want == "" && found:
+				t.Errorf("ScopeCrawls() added WHERE %s", where)
+			case tt.want != "" && !strings.Contains(sql, tt.want):
+				t.Errorf("ScopeCrawls() SQL = %s, want %s", sql, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+// Package quota enforces per-user, per-role and per-organization crawl quotas and reports usage.
+package quota
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"webcrawler-backend/internal/config"
+	"webcrawler-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Quota names, used in errors and API responses
+const (
+	QuotaMaxQueued        = "max_queued"
+	QuotaCrawlsPerDay     = "crawls_per_day"
+	QuotaMaxPagesPerCrawl = "max_pages_per_crawl"
+	QuotaMaxRunning       = "max_running"
+)
+
+// dayWindow is the rolling window of the daily crawl quota
+const dayWindow = 24 * time.Hour
+
+// Limits are crawl quotas; 0 means unlimited
+type Limits struct {
+	MaxQueued        int `json:"max_queued"`          // Crawls waiting in the queue
+	CrawlsPerDay     int `json:"crawls_per_day"`      // Crawls created in the last 24 hours
+	MaxPagesPerCrawl int `json:"max_pages_per_crawl"` // Pages fetched by one site crawl
+	MaxRunning       int `json:"max_running"`         // Crawls running at the same time
+}
+
+// DefaultLimits returns the default quotas of non-admin users
+func DefaultLimits() Limits {
+	return Limits{
+		MaxQueued:        100,
+		CrawlsPerDay:     1000,
+		MaxPagesPerCrawl: 500,
+		MaxRunning:       3,
+	}
+}
+
+// LimitsFromEnv returns the default quotas overridden by QUOTA_* environment variables
+func LimitsFromEnv() Limits {
+	l := DefaultLimits()
+	l.MaxQueued = config.EnvInt("QUOTA_MAX_QUEUED", l.MaxQueued)
+	l.CrawlsPerDay = config.EnvInt("QUOTA_CRAWLS_PER_DAY", l.CrawlsPerDay)
+	l.MaxPagesPerCrawl = config.EnvInt("QUOTA_MAX_PAGES_PER_CRAWL", l.MaxPagesPerCrawl)
+	l.MaxRunning = config.EnvInt("QUOTA_MAX_RUNNING", l.MaxRunning)
+	return l
+}
+
+// apply overrides the limits set in a quota row
+func (l Limits) apply(q models.QuotaLimit) Limits {
+	if q.MaxQueued != nil {
+		l.MaxQueued = *q.MaxQueued
+	}
+	if q.CrawlsPerDay != nil {
+		l.CrawlsPerDay = *q.CrawlsPerDay
+	}
+	if q.MaxPagesPerCrawl != nil {
+		l.MaxPagesPerCrawl = *q.MaxPagesPerCrawl
+	}
+	if q.MaxRunning != nil {
+		l.MaxRunning = *q.MaxRunning
+	}
+	return l
+}
+
+// Usage is the current consumption of a user or organization
+type Usage struct {
+	Queued      int64 `json:"queued"`
+	Running     int64 `json:"running"`
+	CrawlsToday int64 `json:"crawls_today"` // Created in the last 24 hours, deleted ones included
+}
+
+// ExceededError reports which quota a request would exceed
+type ExceededError struct {
+	Quota      string
+	Limit      int
+	Used       int64
+	RetryAfter time.Duration // 0 when unknown (e.g. waiting for queued crawls to finish)
+}
+
+func (e *ExceededError) Error() string {
+	switch e.Quota {
+	case QuotaMaxQueued:
+		return fmt.Sprintf("Quota exceeded: at most %d queued crawls", e.Limit)
+	case QuotaCrawlsPerDay:
+		return fmt.Sprintf("Quota exceeded: at most %d crawls per day", e.Limit)
+	case QuotaMaxPagesPerCrawl:
+		return fmt.Sprintf("Quota exceeded: at most %d pages per crawl", e.Limit)
+	case QuotaMaxRunning:
+		return fmt.Sprintf("Quota exceeded: at most %d crawls running at the same time", e.Limit)
+	}
+	return "Quota exceeded"
+}
+
+// Service resolves quotas and checks them against usage
+type Service struct {
+	db       *gorm.DB
+	defaults Limits
+}
+
+// NewService creates a quota service with the given default limits
+func NewService(db *gorm.DB, defaults Limits) *Service {
+	return &Service{db: db, defaults: defaults}
+}
+
+// Defaults returns the default limits of non-admin users
+func (s *Service) Defaults() Limits {
+	return s.defaults
+}
+
+// UserLimits returns the user's quotas: defaults, then role overrides, then user overrides.
+// Admins are unlimited unless an override says otherwise.
+func (s *Service) UserLimits(user models.User) (Limits, error) {
+	limits := s.defaults
+	if user.Role == models.RoleAdmin {
+		limits = Limits{}
+	}
+
+	var overrides []models.QuotaLimit
+	if err := s.db.Where("(scope = ? AND subject = ?) OR (scope = ? AND subject = ?)",
+		models.QuotaScopeRole, user.Role, models.QuotaScopeUser, strconv.FormatUint(uint64(user.ID), 10)).
+		Find(&overrides).Error; err != nil {
+		return Limits{}, err
+	}
+
+	// Role first so the user override wins
+	for _, scope := range []string{models.QuotaScopeRole, models.QuotaScopeUser} {
+		for _, q := range overrides {
+			if q.Scope == scope {
+				limits = limits.apply(q)
+			}
+		}
+	}
+	return limits, nil
+}
+
+// OrganizationLimits returns the organization's quotas, unlimited unless overridden
+func (s *Service) OrganizationLimits(organizationID uint) (Limits, error) {
+	var q models.QuotaLimit
+	err := s.db.Where("scope = ? AND subject = ?", models.QuotaScopeOrganization, strconv.FormatUint(uint64(organizationID), 10)).First(&q).Error
+	if err == gorm.ErrRecordNotFound {
+		return Limits{}, nil
+	} else if err != nil {
+		return Limits{}, err
+	}
+	return Limits{}.apply(q), nil
+}
+
+// UserUsage returns the crawls owned by the user
+func (s *Service) UserUsage(userID uint) (Usage, error) {
+	return s.usage("user_id", userID)
+}
+
+// OrganizationUsage returns the crawls shared with the organization
+func (s *Service) OrganizationUsage(organizationID uint) (Usage, error) {
+	return s.usage("organization_id", organizationID)
+}
+
+func (s *Service) usage(column string, id uint) (Usage, error) {
+	var usage Usage
+	crawls := func() *gorm.DB {
+		return s.db.Model(&models.CrawlResult{}).Where(column+" = ?", id)
+	}
+	if err := crawls().Where("status = ?", models.StatusQueued).Count(&usage.Queued).Error; err != nil {
+		return usage, err
+	}
+	if err := crawls().Where("status = ?", models.StatusRunning).Count(&usage.Running).Error; err != nil {
+		return usage, err
+	}
+	// Deleted crawls still count, otherwise deleting would reset the daily quota
+	if err := crawls().Unscoped().Where("created_at > ?", time.Now().Add(-dayWindow)).Count(&usage.CrawlsToday).Error; err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// CheckEnqueue checks the queue, daily and page quotas of the user (and of the organization, for
+// organization crawls) before creating a crawl. It returns the page budget for the crawl:
+// the requested one, or a single page when none was requested.
+func (s *Service) CheckEnqueue(user models.User, organizationID *uint, requestedPages int) (int, error) {
+	limits, err := s.UserLimits(user)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.checkEnqueue("user_id", user.ID, limits); err != nil {
+		return 0, err
+	}
+	maxPages := limits.MaxPagesPerCrawl
+
+	if organizationID != nil {
+		orgLimits, err := s.OrganizationLimits(*organizationID)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.checkEnqueue("organization_id", *organizationID, orgLimits); err != nil {
+			return 0, err
+		}
+		if orgLimits.MaxPagesPerCrawl > 0 && (maxPages == 0 || orgLimits.MaxPagesPerCrawl < maxPages) {
+			maxPages = orgLimits.MaxPagesPerCrawl
+		}
+	}
+
+	if requestedPages <= 0 {
+		return 1, nil // Only the URL itself, as before crawls followed links
+	}
+	if maxPages > 0 && requestedPages > maxPages {
+		return 0, &ExceededError{Quota: QuotaMaxPagesPerCrawl, Limit: maxPages, Used: int64(requestedPages)}
+	}
+	return requestedPages, nil
+}
+
+// Enqueue checks the quotas like CheckEnqueue and calls create with the page budget in the
+// same transaction. The user's row, and the organization's for organization crawls, is locked
+// first, so concurrent requests of one owner are checked one at a time and cannot all take
+// the last free slot.
+func (s *Service) Enqueue(user models.User, organizationID *uint, requestedPages int, create func(tx *gorm.DB, maxPages int) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		lock := func(model interface{}, id uint) error {
+			return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(model, id).Error
+		}
+		if err := lock(&models.User{}, user.ID); err != nil {
+			return err
+		}
+		if organizationID != nil {
+			if err := lock(&models.Organization{}, *organizationID); err != nil {
+				return err
+			}
+		}
+
+		maxPages, err := NewService(tx, s.defaults).CheckEnqueue(user, organizationID, requestedPages)
+		if err != nil {
+			return err
+		}
+		return create(tx, maxPages)
+	})
+}
+
+func (s *Service) checkEnqueue(column string, id uint, limits Limits) error {
+	usage, err := s.usage(column, id)
+	if err != nil {
+		return err
+	}
+
+	if limits.MaxQueued > 0 && usage.Queued >= int64(limits.MaxQueued) {
+		return &ExceededError{Quota: QuotaMaxQueued, Limit: limits.MaxQueued, Used: usage.Queued}
+	}
+
+	if limits.CrawlsPerDay > 0 && usage.CrawlsToday >= int64(limits.CrawlsPerDay) {
+		exceeded := &ExceededError{Quota: QuotaCrawlsPerDay, Limit: limits.CrawlsPerDay, Used: usage.CrawlsToday}
+
+		// The quota frees up when the oldest crawl of the window leaves it
+		var oldest models.CrawlResult
+		if err := s.db.Unscoped().Where(column+" = ? AND created_at > ?", id, time.Now().Add(-dayWindow)).
+			Order("created_at asc").First(&oldest).Error; err == nil {
+			exceeded.RetryAfter = time.Until(oldest.CreatedAt.Add(dayWindow))
+		}
+		return exceeded
+	}
+
+	return nil
+}
+
+// CheckStart checks the concurrent running quota of the crawl's owner and organization
+func (s *Service) CheckStart(crawl models.CrawlResult) error {
+	if crawl.UserID != nil {
+		var owner models.User
+		if err := s.db.First(&owner, *crawl.UserID).Error; err != nil {
+			return err
+		}
+		limits, err := s.UserLimits(owner)
+		if err != nil {
+			return err
+		}
+		if err := s.checkRunning("user_id", owner.ID, limits); err != nil {
+			return err
+		}
+	}
+
+	if crawl.OrganizationID != nil {
+		limits, err := s.OrganizationLimits(*crawl.OrganizationID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkRunning("organization_id", *crawl.OrganizationID, limits); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) checkRunning(column string, id uint, limits Limits) error {
+	if limits.MaxRunning <= 0 {
+		return nil
+	}
+	var running int64
+	if err := s.db.Model(&models.CrawlResult{}).Where(column+" = ? AND status = ?", id, models.StatusRunning).
+		Count(&running).Error; err != nil {
+		return err
+	}
+	if running >= int64(limits.MaxRunning) {
+		return &ExceededError{Quota: QuotaMaxRunning, Limit: limits.MaxRunning, Used: running}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// Package secrets encrypts secrets stored in the database with envelope encryption:
+// every value is sealed with its own random data key, which is sealed with a versioned master key.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// envelopePrefix starts every sealed value: enc:v1:<key version>:<sealed data key>:<sealed value>
+const envelopePrefix = "enc:v1:"
+
+var (
+	// ErrNotConfigured is returned when no master key is configured
+	ErrNotConfigured = errors.New("secrets encryption is not configured (set SECRETS_KEYS or SECRETS_KEY)")
+	// ErrNotEncrypted is returned by Open for values that were stored before encryption was enabled
+	ErrNotEncrypted = errors.New("value is not encrypted")
+)
+
+// Keyring holds the master keys by version. New values are sealed with the active key;
+// values sealed with any known key can be opened, so keys can be rotated without downtime.
+type Keyring struct {
+	keys   map[uint32]cipher.AEAD
+	active uint32
+}
+
+// NewKeyring creates a keyring from 32-byte master keys by version
+func NewKeyring(keys map[uint32][]byte, active uint32) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNotConfigured
+	}
+	k := &Keyring{keys: make(map[uint32]cipher.AEAD, len(keys)), active: active}
+	for version, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("master key %d: %v", version, err)
+		}
+		k.keys[version] = aead
+	}
+	if _, ok := k.keys[active]; !ok {
+		return nil, fmt.Errorf("active master key %d is not configured", active)
+	}
+	return k, nil
+}
+
+// KeyringFromEnv loads the master keys, as "version:base64" entries, from SECRETS_KEYS
+// (comma-separated) and SECRETS_KEYS_FILE (one per line). A single SECRETS_KEY is version 1.
+// The active key is SECRETS_ACTIVE_KEY_VERSION, or the highest version.
+// It returns nil without error when no key is configured.
+func KeyringFromEnv() (*Keyring, error) {
+	keys := make(map[uint32][]byte)
+	if key := strings.TrimSpace(os.Getenv("SECRETS_KEY")); key != "" {
+		if err := addKey(keys, "1:"+key); err != nil {
+			return nil, fmt.Errorf("SECRETS_KEY: %v", err)
+		}
+	}
+	for _, entry := range strings.Split(os.Getenv("SECRETS_KEYS"), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			if err := addKey(keys, entry); err != nil {
+				return nil, fmt.Errorf("SECRETS_KEYS: %v", err)
+			}
+		}
+	}
+	if path := os.Getenv("SECRETS_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("SECRETS_KEYS_FILE: %v", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := addKey(keys, line); err != nil {
+				return nil, fmt.Errorf("SECRETS_KEYS_FILE: %v", err)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	versions := make([]uint32, 0, len(keys))
+	for version := range keys {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	active := versions[len(versions)-1]
+	if v := os.Getenv("SECRETS_ACTIVE_KEY_VERSION"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("SECRETS_ACTIVE_KEY_VERSION: %v", err)
+		}
+		active = uint32(parsed)
+	}
+	return NewKeyring(keys, active)
+}
+
+// addKey parses a "version:base64" entry
+func addKey(keys map[uint32][]byte, entry string) error {
+	versionText, encoded, ok := strings.Cut(entry, ":")
+	if !ok {
+		return fmt.Errorf("keys must be written as version:base64")
+	}
+	version, err := strconv.ParseUint(strings.TrimSpace(versionText), 10, 32)
+	if err != nil || version == 0 {
+		return fmt.Errorf("invalid key version %q", versionText)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return fmt.Errorf("key %d is not valid base64", version)
+	}
+	if _, exists := keys[uint32(version)]; exists {
+		return fmt.Errorf("key %d is configured twice", version)
+	}
+	keys[uint32(version)] = key
+	return nil
+}
+
+// ActiveVersion returns the version of the key new values are sealed with
+func (k *Keyring) ActiveVersion() uint32 {
+	return k.active
+}
+
+// Seal encrypts plaintext with a new data key sealed by the active master key
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNotConfigured
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	sealedKey, err := seal(k.keys[k.active], dataKey)
+	if err != nil {
+		return nil, err
+	}
+	sealedValue, err := seal(dataAEAD, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	out := fmt.Sprintf("%s%d:%s:%s", envelopePrefix, k.active,
+		base64.RawStdEncoding.EncodeToString(sealedKey),
+		base64.RawStdEncoding.EncodeToString(sealedValue))
+	return []byte(out), nil
+}
+
+// Open decrypts a sealed value. Values without the envelope prefix are tried as raw AES-GCM
+// data sealed with key 1 (the format before key versioning); ErrNotEncrypted means they are plaintext.
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(envelopePrefix)) {
+		if k != nil {
+			if legacy, ok := k.keys[1]; ok {
+				if plaintext, err := open(legacy, data); err == nil {
+					return plaintext, nil
+				}
+			}
+		}
+		return nil, ErrNotEncrypted
+	}
+	if k == nil {
+		return nil, ErrNotConfigured
+	}
+
+	parts := strings.Split(string(data[len(envelopePrefix):]), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed encrypted value")
+	}
+	version, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, errors.New("malformed encrypted value")
+	}
+	master, ok := k.keys[uint32(version)]
+	if !ok {
+		return nil, fmt.Errorf("master key %d is not configured", version)
+	}
+	sealedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed encrypted value")
+	}
+	sealedValue, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed encrypted value")
+	}
+
+	dataKey, err := open(master, sealedKey)
+	if err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataAEAD, sealedValue)
+}
+
+// IsCurrent reports whether a stored value is sealed with the active key
+func (k *Keyring) IsCurrent(data []byte) bool {
+	return k != nil && bytes.HasPrefix(data, []byte(fmt.Sprintf("%s%d:", envelopePrefix, k.active)))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns the nonce followed by the ciphertext
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	size := aead.NonceSize()
+	if len(data) < size+aead.Overhead() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	return aead.Open(nil, data[:size], data[size:], nil)
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// SetDefault sets the keyring used by the GORM serializer
+func SetDefault(k *Keyring) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultKeyring = k
+}
+
+// Default returns the keyring used by the GORM serializer, nil when encryption is not configured
+func Default() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultKeyring
+}
+
+// Configured reports whether a default keyring is set
+func Configured() bool {
+	return Default() != nil
+}
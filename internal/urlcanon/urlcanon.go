@@ -0,0 +1,286 @@
+// Package urlcanon rewrites URLs to a canonical form so that equivalent spellings of the same
+// address compare equal.
+package urlcanon
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultStripParams are the tracking parameters removed by default. A trailing "*" matches
+// any parameter starting with the prefix.
+var DefaultStripParams = []string{
+	"utm_*", "gclid", "gbraid", "wbraid", "dclid", "fbclid", "msclkid", "yclid", "igshid",
+	"mc_cid", "mc_eid", "_ga", "_gl", "_hsenc", "_hsmi", "mkt_tok",
+}
+
+// defaultPorts are removed from URLs of their scheme
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// hostProfile converts internationalized host names to punycode. Underscores and other
+// characters outside STD3 rules are allowed since real host names use them.
+var hostProfile = idna.New(idna.MapForLookup(), idna.Transitional(false), idna.StrictDomainName(false))
+
+// Options configure a Canonicalizer
+type Options struct {
+	// StripParams are query parameters removed from URLs, matched case-insensitively;
+	// a trailing "*" matches a prefix
+	StripParams []string
+	// KeepFragment keeps the fragment, which is otherwise removed
+	KeepFragment bool
+}
+
+// Canonicalizer rewrites URLs to their canonical form:
+//   - lowercase scheme and host, internationalized hosts in punycode, no trailing dot
+//   - no default port
+//   - "." and ".." path segments resolved, and an empty path written "/"
+//   - percent-encoding normalized: unreserved characters decoded, other escapes in uppercase
+//   - query parameters sorted, with tracking parameters removed
+//   - no fragment
+type Canonicalizer struct {
+	strip        map[string]bool
+	stripPrefix  []string
+	keepFragment bool
+}
+
+// New creates a canonicalizer
+func New(options Options) *Canonicalizer {
+	c := &Canonicalizer{strip: make(map[string]bool), keepFragment: options.KeepFragment}
+	for _, param := range options.StripParams {
+		param = strings.ToLower(strings.TrimSpace(param))
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			c.stripPrefix = append(c.stripPrefix, prefix)
+		} else if param != "" {
+			c.strip[param] = true
+		}
+	}
+	return c
+}
+
+// FromEnv creates a canonicalizer configured by URL_STRIP_PARAMS (comma-separated, replacing
+// the defaults; "none" keeps every parameter) and URL_KEEP_FRAGMENT
+func FromEnv() *Canonicalizer {
+	options := Options{StripParams: DefaultStripParams, KeepFragment: os.Getenv("URL_KEEP_FRAGMENT") == "true"}
+	if value := strings.TrimSpace(os.Getenv("URL_STRIP_PARAMS")); value == "none" {
+		options.StripParams = nil
+	} else if value != "" {
+		options.StripParams = strings.Split(value, ",")
+	}
+	return New(options)
+}
+
+// Canonicalize returns the canonical form of an absolute URL
+func (c *Canonicalizer) Canonicalize(u *url.URL) (*url.URL, error) {
+	if u.Opaque != "" || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", u.String())
+	}
+	scheme := strings.ToLower(u.Scheme)
+
+	host, err := canonicalHost(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if port := strings.TrimLeft(u.Port(), "0"); port != "" && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteByte('@')
+	}
+	b.WriteString(host)
+
+	path := removeDotSegments(normalizeEscapes(u.EscapedPath(), isPathChar))
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(path)
+
+	if query := c.canonicalQuery(u.RawQuery); query != "" {
+		b.WriteByte('?')
+		b.WriteString(query)
+	}
+	if c.keepFragment && u.Fragment != "" {
+		b.WriteByte('#')
+		b.WriteString(normalizeEscapes(u.EscapedFragment(), isFragmentChar))
+	}
+	return url.Parse(b.String())
+}
+
+// CanonicalizeString parses and canonicalizes a URL
+func (c *Canonicalizer) CanonicalizeString(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	canonical, err := c.Canonicalize(u)
+	if err != nil {
+		return "", err
+	}
+	return canonical.String(), nil
+}
+
+// canonicalHost lowercases a host and converts it to punycode
+func canonicalHost(host string) (string, error) {
+	if strings.Contains(host, ":") {
+		return "[" + strings.ToLower(host) + "]", nil // IPv6 literal
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", fmt.Errorf("URL host is required")
+	}
+	ascii, err := hostProfile.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q: %v", host, err)
+	}
+	return strings.ToLower(ascii), nil
+}
+
+// canonicalQuery removes the stripped parameters and sorts the others by name, then value
+func (c *Canonicalizer) canonicalQuery(rawQuery string) string {
+	type param struct{ name, pair string }
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		pair = normalizeEscapes(pair, isQueryChar)
+		name, _, _ := strings.Cut(pair, "=")
+		if c.stripped(name) {
+			continue
+		}
+		params = append(params, param{name: name, pair: pair})
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].pair < params[j].pair
+	})
+
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+// stripped reports whether a query parameter is removed
+func (c *Canonicalizer) stripped(name string) bool {
+	if decoded, err := url.QueryUnescape(name); err == nil {
+		name = decoded
+	}
+	name = strings.ToLower(name)
+	if c.strip[name] {
+		return true
+	}
+	for _, prefix := range c.stripPrefix {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes decodes escaped unreserved characters, uppercases the other escapes,
+// and escapes the characters that may not appear unescaped
+func normalizeEscapes(s string, allowed func(byte) bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(hex[decoded>>4])
+				b.WriteByte(hex[decoded&15])
+			}
+			i += 2
+			continue
+		}
+		if ch != '%' && allowed(ch) {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&15])
+	}
+	return b.String()
+}
+
+// removeDotSegments resolves "." and ".." segments as in RFC 3986, section 5.2.4
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			// The leading empty segment of an absolute path is never removed
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func isUnreserved(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' ||
+		ch == '-' || ch == '.' || ch == '_' || ch == '~'
+}
+
+func isSubDelim(ch byte) bool {
+	return strings.IndexByte("!$&'()*+,;=", ch) >= 0
+}
+
+func isPathChar(ch byte) bool {
+	return isUnreserved(ch) || isSubDelim(ch) || ch == ':' || ch == '@' || ch == '/'
+}
+
+func isQueryChar(ch byte) bool {
+	return isPathChar(ch) || ch == '?'
+}
+
+func isFragmentChar(ch byte) bool {
+	return isPathChar(ch) || ch == '?'
+}
+
+func isHex(ch byte) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func unhex(ch byte) byte {
+	switch {
+	case ch <= '9':
+		return ch - '0'
+	case ch <= 'F':
+		return ch - 'A' + 10
+	}
+	return ch - 'a' + 10
+}
@@ -0,0 +1,99 @@
+package urlcanon
+
+import "testing"
+
+func TestCanonicalizeString(t *testing.T) {
+	canon := New(Options{StripParams: DefaultStripParams})
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"lowercase scheme and host", "HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"empty path", "https://example.com", "https://example.com/"},
+		{"trailing dot", "https://example.com./", "https://example.com/"},
+		{"internationalized host", "https://bücher.example/", "https://xn--bcher-kva.example/"},
+		{"default https port", "https://example.com:443/", "https://example.com/"},
+		{"default http port", "http://example.com:80/a", "http://example.com/a"},
+		{"default port with leading zeros", "https://example.com:0443/", "https://example.com/"},
+		{"other port kept", "https://example.com:8443/", "https://example.com:8443/"},
+		{"https port on http kept", "http://example.com:443/", "http://example.com:443/"},
+		{"IPv6 literal", "http://[2001:DB8::1]:80/", "http://[2001:db8::1]/"},
+		{"fragment removed", "https://example.com/page#section", "https://example.com/page"},
+		{"dot segment", "https://example.com/a/./b", "https://example.com/a/b"},
+		{"dot-dot segment", "https://example.com/a/b/../c", "https://example.com/a/c"},
+		{"dot-dot above the root", "https://example.com/../../a", "https://example.com/a"},
+		{"trailing dot-dot", "https://example.com/a/b/..", "https://example.com/a/"},
+		{"query sorted", "https://example.com/?b=2&a=1&c=3", "https://example.com/?a=1&b=2&c=3"},
+		{"repeated parameter sorted by value", "https://example.com/?a=2&a=1", "https://example.com/?a=1&a=2"},
+		{"tracking parameters stripped", "https://example.com/?utm_source=x&id=7&UTM_Medium=y&fbclid=z", "https://example.com/?id=7"},
+		{"only tracking parameters", "https://example.com/?utm_source=x", "https://example.com/"},
+		{"escaped unreserved characters decoded", "https://example.com/%7Euser/%61bc", "https://example.com/~user/abc"},
+		{"escapes uppercased", "https://example.com/a%2fb?q=%c3%a9", "https://example.com/a%2Fb?q=%C3%A9"},
+		{"unsafe characters escaped", "https://example.com/a b", "https://example.com/a%20b"},
+		{"user info kept", "https://user@Example.com/", "https://user@example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canon.CanonicalizeString(tt.in)
+			if err != nil {
+				t.Fatalf("CanonicalizeString(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("CanonicalizeString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// The spellings the request names are all the same page
+func TestCanonicalizeStringEquivalent(t *testing.T) {
+	canon := New(Options{StripParams: DefaultStripParams})
+	var first string
+	for i, in := range []string{"https://Example.com", "https://example.com:443/", "https://example.com/?utm_source=x", "https://example.com/#top"} {
+		got, err := canon.CanonicalizeString(in)
+		if err != nil {
+			t.Fatalf("CanonicalizeString(%q) error = %v", in, err)
+		}
+		if i == 0 {
+			first = got
+		} else if got != first {
+			t.Errorf("CanonicalizeString(%q) = %q, want %q", in, got, first)
+		}
+	}
+}
+
+func TestCanonicalizeOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		in      string
+		want    string
+	}{
+		{"fragment kept", Options{KeepFragment: true}, "https://example.com/#Top%7e", "https://example.com/#Top~"},
+		{"no parameters stripped", Options{}, "https://example.com/?utm_source=x", "https://example.com/?utm_source=x"},
+		{"custom parameter", Options{StripParams: []string{"Session"}}, "https://example.com/?session=1&page=2", "https://example.com/?page=2"},
+		{"custom prefix", Options{StripParams: []string{"ref_*"}}, "https://example.com/?ref_a=1&ref=2", "https://example.com/?ref=2"},
+		{"escaped parameter name", Options{StripParams: []string{"utm_*"}}, "https://example.com/?utm%5Fsource=x", "https://example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.options).CanonicalizeString(tt.in)
+			if err != nil {
+				t.Fatalf("CanonicalizeString(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("CanonicalizeString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeStringErrors(t *testing.T) {
+	canon := New(Options{})
+	for _, in := range []string{"/relative/path", "mailto:someone@example.com", "https:///path"} {
+		if got, err := canon.CanonicalizeString(in); err == nil {
+			t.Errorf("CanonicalizeString(%q) = %q, want an error", in, got)
+		}
+	}
+}
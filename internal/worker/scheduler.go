@@ -0,0 +1,187 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+	"webcrawler-backend/internal/models"
+	"webcrawler-backend/internal/quota"
+
+	"gorm.io/gorm"
+)
+
+// Scheduler picks the next queued crawl. Higher priorities go first; within a priority,
+// owners (organizations, or users for personal crawls) take turns so one owner's
+// bulk import cannot starve the others.
+type Scheduler struct {
+	db     *gorm.DB
+	quotas *quota.Service
+
+	mu         sync.Mutex
+	lastServed map[string]time.Time // Owner key -> last dispatch
+}
+
+// NewScheduler creates a fair-share scheduler
+func NewScheduler(db *gorm.DB, quotas *quota.Service) *Scheduler {
+	return &Scheduler{
+		db:         db,
+		quotas:     quotas,
+		lastServed: make(map[string]time.Time),
+	}
+}
+
+// queuedOwner is an owner with queued crawls at a priority
+type queuedOwner struct {
+	UserID         *uint
+	OrganizationID *uint
+	OldestAt       time.Time
+}
+
+func (o queuedOwner) key() string {
+	return ownerKey(o.UserID, o.OrganizationID)
+}
+
+// ownerKey identifies who a crawl is accounted to: its organization, or its user for personal crawls
+func ownerKey(userID, organizationID *uint) string {
+	switch {
+	case organizationID != nil:
+		return fmt.Sprintf("org:%d", *organizationID)
+	case userID != nil:
+		return fmt.Sprintf("user:%d", *userID)
+	}
+	return "anonymous"
+}
+
+// Next claims the next crawl to run and marks it running. It returns nil when nothing is runnable.
+func (s *Scheduler) Next() (*models.CrawlResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var queued []struct {
+		Priority       models.CrawlPriority
+		UserID         *uint
+		OrganizationID *uint
+	}
+	if err := s.db.Model(&models.CrawlResult{}).Where("status = ?", models.StatusQueued).
+		Distinct("priority", "user_id", "organization_id").Scan(&queued).Error; err != nil {
+		return nil, err
+	}
+
+	// Forget owners with nothing queued, so the map does not grow with every owner ever served
+	waiting := make(map[string]bool)
+	var priorities []models.CrawlPriority
+	for _, q := range queued {
+		waiting[ownerKey(q.UserID, q.OrganizationID)] = true
+		if !slices.Contains(priorities, q.Priority) {
+			priorities = append(priorities, q.Priority)
+		}
+	}
+	for key := range s.lastServed {
+		if !waiting[key] {
+			delete(s.lastServed, key)
+		}
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] > priorities[j] })
+
+	for _, priority := range priorities {
+		crawl, err := s.nextAtPriority(priority)
+		if err != nil || crawl != nil {
+			return crawl, err
+		}
+	}
+	return nil, nil
+}
+
+// nextAtPriority serves the owner waiting the longest since its last turn
+func (s *Scheduler) nextAtPriority(priority models.CrawlPriority) (*models.CrawlResult, error) {
+	var owners []queuedOwner
+	if err := s.db.Model(&models.CrawlResult{}).
+		Select("user_id, organization_id, MIN(created_at) AS oldest_at").
+		Where("status = ? AND priority = ?", models.StatusQueued, priority).
+		Group("user_id, organization_id").
+		Scan(&owners).Error; err != nil {
+		return nil, err
+	}
+
+	// Organization crawls created by different members belong to the same owner;
+	// merge their rows and keep the oldest crawl time
+	merged := make(map[string]queuedOwner)
+	for _, o := range owners {
+		if existing, ok := merged[o.key()]; !ok || o.OldestAt.Before(existing.OldestAt) {
+			merged[o.key()] = o
+		}
+	}
+	owners = owners[:0]
+	for _, o := range merged {
+		owners = append(owners, o)
+	}
+
+	sort.Slice(owners, func(i, j int) bool {
+		si, sj := s.lastServed[owners[i].key()], s.lastServed[owners[j].key()]
+		if !si.Equal(sj) {
+			return si.Before(sj)
+		}
+		return owners[i].OldestAt.Before(owners[j].OldestAt)
+	})
+
+	for _, owner := range owners {
+		crawl, err := s.claimOldest(owner, priority)
+		if err != nil {
+			return nil, err
+		}
+		if crawl != nil {
+			s.lastServed[owner.key()] = time.Now()
+			return crawl, nil
+		}
+	}
+	return nil, nil
+}
+
+// claimOldest marks the owner's oldest startable crawl running. Crawls whose owner
+// is at the running quota stay queued.
+func (s *Scheduler) claimOldest(owner queuedOwner, priority models.CrawlPriority) (*models.CrawlResult, error) {
+	query := s.db.Where("status = ? AND priority = ?", models.StatusQueued, priority)
+	if owner.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *owner.OrganizationID)
+	} else if owner.UserID != nil {
+		query = query.Where("user_id = ? AND organization_id IS NULL", *owner.UserID)
+	} else {
+		query = query.Where("user_id IS NULL AND organization_id IS NULL")
+	}
+
+	var crawl models.CrawlResult
+	if err := query.Order("created_at asc").First(&crawl).Error; err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := s.quotas.CheckStart(crawl); err != nil {
+		// At the running quota, or the quota could not be read: leave this owner's crawl
+		// queued and let the others run
+		var exceeded *quota.ExceededError
+		if !errors.As(err, &exceeded) {
+			log.Printf("[WARN] Skipping crawl %d, checking its running quota failed: %v", crawl.ID, err)
+		}
+		return nil, nil
+	}
+
+	// The status condition keeps another worker or a manual run from claiming it too
+	claimed := s.db.Model(&models.CrawlResult{}).
+		Where("id = ? AND status = ?", crawl.ID, models.StatusQueued).
+		Updates(map[string]interface{}{"status": models.StatusRunning, "progress": 0})
+	if claimed.Error != nil {
+		return nil, claimed.Error
+	}
+	if claimed.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	crawl.Status = models.StatusRunning
+	crawl.Progress = 0
+	return &crawl, nil
+}
@@ -0,0 +1,81 @@
+// Package worker processes queued crawls in the background.
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+	"webcrawler-backend/internal/crawler"
+	"webcrawler-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// pollInterval is how long an idle worker waits before looking at the queue again
+const pollInterval = 2 * time.Second
+
+// Worker runs queued crawls picked by the scheduler
+type Worker struct {
+	db          *gorm.DB
+	scheduler   *Scheduler
+	crawler     *crawler.Crawler
+	concurrency int
+}
+
+// New creates a worker running up to concurrency crawls at the same time
+func New(db *gorm.DB, scheduler *Scheduler, crawler *crawler.Crawler, concurrency int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Worker{
+		db:          db,
+		scheduler:   scheduler,
+		crawler:     crawler,
+		concurrency: concurrency,
+	}
+}
+
+// Start runs the worker loops in the background until ctx is cancelled
+func (w *Worker) Start(ctx context.Context) {
+	for i := 0; i < w.concurrency; i++ {
+		go w.loop(ctx)
+	}
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		crawl, err := w.scheduler.Next()
+		if err != nil {
+			log.Printf("[WARN] Failed to dequeue crawl: %v", err)
+		}
+		if crawl != nil {
+			w.crawler.Run(ctx, crawl)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// RequeueInterrupted puts crawls that were running when the server stopped back in the queue,
+// and acknowledges pauses that no worker got to. Their frontier is kept, so they continue
+// where they were interrupted.
+func RequeueInterrupted(db *gorm.DB) error {
+	result := db.Model(&models.CrawlResult{}).
+		Where("status = ?", models.StatusRunning).
+		Update("status", models.StatusQueued)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Requeued %d interrupted crawls", result.RowsAffected)
+	}
+	// Crawls paused while running are not running anymore either
+	return db.Model(&models.CrawlResult{}).
+		Where("status = ? AND paused_at IS NULL", models.StatusPaused).
+		Update("paused_at", time.Now()).Error
+}
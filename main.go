@@ -1,18 +1,29 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
 	"fmt"
+	"webcrawler-backend/internal/audit"
+	"webcrawler-backend/internal/crawler"
 	"webcrawler-backend/internal/database"
 	"webcrawler-backend/internal/handlers"
+	"webcrawler-backend/internal/mailer"
 	"webcrawler-backend/internal/middleware"
+	"webcrawler-backend/internal/netguard"
+	"webcrawler-backend/internal/oidc"
+	"webcrawler-backend/internal/policy"
+	"webcrawler-backend/internal/quota"
+	"webcrawler-backend/internal/secrets"
+	"webcrawler-backend/internal/urlcanon"
+	"webcrawler-backend/internal/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-    "time"
     "webcrawler-backend/internal/models"
-    "gorm.io/gorm"
 )
 
 // logWithLevel logs with a level and context (file, line, function)
@@ -31,12 +42,20 @@ func main() {
 		logWithLevel("INFO", "No .env file found, using system environment variables")
 	}
 
-	// Get JWT secret from environment
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-super-secret-jwt-key-change-in-production" // Default for development
-		logWithLevel("WARN", "Using default JWT secret. Set JWT_SECRET environment variable for production.")
+	// Master keys encrypting stored secrets (site credentials, TOTP seeds, signing keys)
+	keyring, err := secrets.KeyringFromEnv()
+	if err != nil {
+		logWithLevel("ERROR", "Invalid secrets encryption keys: %v", err)
+		os.Exit(1)
+	}
+	if keyring == nil {
+		if os.Getenv("APP_ENV") == "production" {
+			logWithLevel("ERROR", "SECRETS_KEYS must be configured in production")
+			os.Exit(1)
+		}
+		logWithLevel("WARN", "No secrets encryption key configured: secrets are stored in plaintext and crawls with credentials are disabled")
 	}
+	secrets.SetDefault(keyring)
 
 	// Initialize database
 	db, err := database.InitDB()
@@ -53,18 +72,108 @@ func main() {
 	}
 
 
+	// Append-only audit log of security and crawl-management actions
+	auditLog := audit.NewLogger(db)
+
+	// Crawl quotas: defaults from QUOTA_* environment variables, overrides per user, organization or role
+	quotas := quota.NewService(db, quota.LimitsFromEnv())
+
+	// SSRF protection: crawls cannot reach internal addresses unless allowlisted
+	guard, err := netguard.GuardFromEnv()
+	if err != nil {
+		logWithLevel("ERROR", "Invalid SSRF configuration: %v", err)
+		os.Exit(1)
+	}
+	if !guard.Enabled() {
+		logWithLevel("WARN", "SSRF protection is disabled, crawls can reach internal addresses")
+	}
+	networkHandler := handlers.NewNetworkHandler(db, guard, auditLog)
+	if err := networkHandler.ReloadAllowlist(); err != nil {
+		logWithLevel("ERROR", "Failed to load the network allowlist: %v", err)
+		os.Exit(1)
+	}
+
+	// URL canonicalization for duplicate detection and link classification
+	canon := urlcanon.FromEnv()
+
+	// Crawler shared by the worker and the process endpoints
+	siteCrawler := crawler.New(db, guard, canon)
+
 	// Initialize handlers
-	crawlHandler := handlers.NewCrawlHandler(db)
+	crawlHandler := handlers.NewCrawlHandler(db, siteCrawler, guard, canon, quotas, auditLog)
+	organizationHandler := handlers.NewOrganizationHandler(db, auditLog)
+	presetHandler := handlers.NewPresetHandler(db, auditLog)
+	quotaHandler := handlers.NewQuotaHandler(db, quotas, auditLog)
 	
+	// Load JWT signing keys (fails in production when none is configured)
+	signingKeys, err := middleware.NewKeyManager(db, middleware.KeyConfigFromEnv())
+	if err != nil {
+		logWithLevel("ERROR", "Failed to load JWT signing keys: %v", err)
+		os.Exit(1)
+	}
+	signingKeys.StartRotation(context.Background())
+
 	// Initialize auth middleware
-	authMiddleware := middleware.NewAuthMiddleware(db, jwtSecret)
+	authMiddleware := middleware.NewAuthMiddleware(db, signingKeys)
+
+	// Roles that must enable two-factor authentication (e.g. MFA_REQUIRED_ROLES=admin)
+	if roles := os.Getenv("MFA_REQUIRED_ROLES"); roles != "" {
+		authMiddleware.SetMFARequiredRoles(strings.Split(roles, ",")...)
+	}
 	
+	// Initialize mailer (MAIL_DRIVER=smtp|file|db)
+	mail, err := mailer.NewFromEnv(db)
+	if err != nil {
+		logWithLevel("ERROR", "Failed to configure mailer: %v", err)
+		os.Exit(1)
+	}
+
+	// Frontend URL used for links in emails
+	appBaseURL := os.Getenv("APP_BASE_URL")
+	if appBaseURL == "" {
+		appBaseURL = "http://localhost:3000"
+	}
+
+	// Brute-force protection for login and registration (LOGIN_* environment variables)
+	loginGuard := middleware.NewLoginGuard(db, middleware.LoginPolicyFromEnv())
+
 	// Initialize auth handler
-	authHandler := handlers.NewAuthHandler(db, authMiddleware)
+	authHandler := handlers.NewAuthHandler(db, authMiddleware, mail, appBaseURL, loginGuard, auditLog)
+
+	// Initialize admin handler
+	adminHandler := handlers.NewAdminHandler(db, authMiddleware, loginGuard, auditLog)
+
+	// Single sign-on through an OpenID Connect provider (enabled when OIDC_ISSUER_URL is set)
+	var oidcHandler *handlers.OIDCHandler
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		provider := oidc.NewProvider(oidc.Config{
+			IssuerURL:    issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+		})
+		oidcHandler = handlers.NewOIDCHandler(db, provider, authHandler,
+			handlers.ParseRoleMapping(os.Getenv("OIDC_ROLE_MAPPING")), os.Getenv("OIDC_POST_LOGIN_REDIRECT"))
+		logWithLevel("INFO", "OIDC single sign-on enabled with issuer %s", issuer)
+	}
 
 	// Setup Gin router
 	r := gin.Default()
 
+	// Client IPs drive login throttling and the audit log, so X-Forwarded-For is only
+	// believed from the proxies listed in TRUSTED_PROXIES (none by default)
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		logWithLevel("ERROR", "Invalid TRUSTED_PROXIES: %v", err)
+		os.Exit(1)
+	}
+
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
@@ -84,13 +193,48 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Public signing keys for services verifying our tokens
+	r.GET("/.well-known/jwks.json", authMiddleware.JWKS)
+
 	// Auth routes (no auth required)
 	auth := r.Group("/api/auth")
 	{
 		auth.POST("/login", authHandler.Login)
 		auth.POST("/register", authHandler.Register)
-		auth.POST("/refresh", authMiddleware.AuthRequired(), authHandler.RefreshToken)
-		auth.POST("/logout", authMiddleware.AuthRequired(), authHandler.Logout)
+		auth.POST("/refresh", authMiddleware.AuthRequiredForMFASetup(), authHandler.RefreshToken)
+		auth.POST("/logout", authMiddleware.AuthRequiredForMFASetup(), authHandler.Logout)
+		auth.POST("/verify-email", authHandler.VerifyEmail)
+		auth.POST("/verify-email/resend", authMiddleware.AuthRequired(), authHandler.ResendVerificationEmail)
+		auth.POST("/forgot-password", authHandler.ForgotPassword)
+		auth.POST("/reset-password", authHandler.ResetPassword)
+		auth.POST("/change-password", authMiddleware.AuthRequired(), authMiddleware.NotImpersonating(), authHandler.ChangePassword)
+
+		// Two-factor authentication
+		auth.POST("/mfa/verify", authHandler.VerifyMFA)
+		auth.POST("/mfa/enroll", authMiddleware.AuthRequiredForMFASetup(), authMiddleware.NotImpersonating(), authHandler.EnrollMFA)
+		auth.POST("/mfa/activate", authMiddleware.AuthRequiredForMFASetup(), authMiddleware.NotImpersonating(), authHandler.ActivateMFA)
+		auth.POST("/mfa/disable", authMiddleware.AuthRequired(), authMiddleware.NotImpersonating(), authHandler.DisableMFA)
+		auth.POST("/mfa/recovery-codes", authMiddleware.AuthRequired(), authMiddleware.NotImpersonating(), authHandler.RegenerateRecoveryCodes)
+
+		// Single sign-on
+		if oidcHandler != nil {
+			auth.GET("/oidc/login", oidcHandler.Login)
+			auth.GET("/oidc/callback", oidcHandler.Callback)
+		}
+	}
+
+	// Route-level role checks derived from the policy permission matrix
+	canRead := authMiddleware.RoleRequired(policy.RolesAllowed(policy.ActionRead)...)
+	canCreate := authMiddleware.RoleRequired(policy.RolesAllowed(policy.ActionCreate)...)
+	canProcess := authMiddleware.RoleRequired(policy.RolesAllowed(policy.ActionProcess)...)
+	canStop := authMiddleware.RoleRequired(policy.RolesAllowed(policy.ActionStop)...)
+	canDelete := authMiddleware.RoleRequired(policy.RolesAllowed(policy.ActionDelete)...)
+	canPrioritize := authMiddleware.RoleRequired(policy.RolesAllowed(policy.ActionPrioritize)...)
+
+	// Optionally block crawl creation until the user's email is verified
+	createCrawlHandlers := []gin.HandlerFunc{canCreate, crawlHandler.CreateCrawlResult}
+	if os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true" {
+		createCrawlHandlers = append([]gin.HandlerFunc{authMiddleware.EmailVerifiedRequired()}, createCrawlHandlers...)
 	}
 
 	// Protected API routes
@@ -102,54 +246,73 @@ func main() {
 		api.PUT("/profile", authHandler.UpdateProfile)
 		
 		// Crawl routes
-		api.GET("/crawls", crawlHandler.GetCrawlResults)
-		api.POST("/crawls", crawlHandler.CreateCrawlResult)
-		api.GET("/crawls/:id", crawlHandler.GetCrawlResultByID)
-		api.GET("/crawls/:id/broken-links", crawlHandler.GetBrokenLinks)
-		api.POST("/crawls/:id/process", crawlHandler.CrawlSingleURL)
-		api.POST("/crawls/:id/stop", crawlHandler.StopCrawlByID)
-		api.DELETE("/crawls/:id", crawlHandler.DeleteCrawlResult)
-		api.POST("/crawls/process-all", crawlHandler.ProcessQueuedCrawls)
-		api.GET("/stats", crawlHandler.GetStats)
+		api.GET("/crawls", canRead, crawlHandler.GetCrawlResults)
+		api.POST("/crawls", createCrawlHandlers...)
+		api.GET("/crawls/:id", canRead, crawlHandler.GetCrawlResultByID)
+		api.GET("/crawls/:id/broken-links", canRead, crawlHandler.GetBrokenLinks)
+		api.POST("/crawls/:id/process", canProcess, crawlHandler.CrawlSingleURL)
+		api.POST("/crawls/:id/stop", canStop, crawlHandler.StopCrawlByID)
+		api.POST("/crawls/:id/pause", canStop, crawlHandler.PauseCrawl)
+		api.POST("/crawls/:id/resume", canProcess, crawlHandler.ResumeCrawl)
+		api.GET("/crawls/:id/pages", canRead, crawlHandler.GetCrawlPages)
+		api.GET("/crawls/:id/issues", canRead, crawlHandler.GetCrawlIssues)
+		api.PUT("/crawls/:id/priority", canPrioritize, crawlHandler.SetCrawlPriority)
+		api.DELETE("/crawls/:id", canDelete, crawlHandler.DeleteCrawlResult)
+		api.POST("/crawls/process-all", canProcess, crawlHandler.ProcessQueuedCrawls)
+		api.GET("/stats", canRead, crawlHandler.GetStats)
+		api.GET("/usage", quotaHandler.GetUsage)
+
+		// Crawl presets (managing them takes the same role as creating crawls)
+		api.GET("/presets", presetHandler.ListPresets)
+		api.GET("/presets/:id", presetHandler.GetPreset)
+		api.POST("/presets", canCreate, presetHandler.CreatePreset)
+		api.PUT("/presets/:id", canCreate, presetHandler.UpdatePreset)
+		api.DELETE("/presets/:id", canCreate, presetHandler.DeletePreset)
+
+		// Organization routes
+		api.GET("/orgs", organizationHandler.ListOrganizations)
+		api.POST("/orgs", organizationHandler.CreateOrganization)
+		api.GET("/orgs/:id", organizationHandler.GetOrganization)
+		api.PUT("/orgs/:id", organizationHandler.UpdateOrganization)
+		api.DELETE("/orgs/:id", organizationHandler.DeleteOrganization)
+		api.POST("/orgs/:id/members", organizationHandler.AddMember)
+		api.PUT("/orgs/:id/members/:user_id", organizationHandler.UpdateMember)
+		api.DELETE("/orgs/:id/members/:user_id", organizationHandler.RemoveMember)
 	}
 
 	// Admin routes (admin role required)
 	admin := r.Group("/api/admin")
-	admin.Use(authMiddleware.RoleRequired("admin"))
+	admin.Use(authMiddleware.RoleRequired(models.RoleAdmin))
 	{
-		// Add admin-specific routes here
-		admin.GET("/users", func(c *gin.Context) {
-			c.JSON(200, gin.H{"message": "Admin endpoint - user management coming soon"})
-		})
+		// User management
+		admin.GET("/users", adminHandler.ListUsers)
+		admin.GET("/users/:id", adminHandler.GetUser)
+		admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
+		admin.POST("/users/:id/unlock", adminHandler.UnlockUser)
+		admin.POST("/users/:id/impersonate", adminHandler.ImpersonateUser)
+
+		// Quotas
+		admin.GET("/quotas", quotaHandler.ListQuotas)
+		admin.PUT("/quotas/:scope/:subject", quotaHandler.SetQuota)
+		admin.DELETE("/quotas/:scope/:subject", quotaHandler.DeleteQuota)
+
+		// Internal networks crawls may reach despite the SSRF protection
+		admin.GET("/network-allowlist", networkHandler.ListAllowlist)
+		admin.POST("/network-allowlist", networkHandler.AddAllowlistEntry)
+		admin.DELETE("/network-allowlist/:id", networkHandler.DeleteAllowlistEntry)
+
+		// Audit log
+		admin.GET("/audit-logs", adminHandler.ListAuditLogs)
+		admin.GET("/audit-logs/export", adminHandler.ExportAuditLogs)
 	}
 
-	// Start background worker to process queued crawls automatically
-go func(db *gorm.DB) {
-    for {
-        var crawl models.CrawlResult
-        // Find the oldest crawl with status "queued"
-        if err := db.Where("status = ?", models.StatusQueued).Order("created_at asc").First(&crawl).Error; err == nil {
-            // Set to running
-            db.Model(&crawl).Updates(map[string]interface{}{
-                "status":   models.StatusRunning,
-                "progress": 0,
-            })
-
-            // Simulate crawl progress
-            for i := 20; i <= 100; i += 20 {
-                time.Sleep(1 * time.Second) // Simulate work
-                db.Model(&crawl).Update("progress", i)
-            }
-
-            // Set to done
-            db.Model(&crawl).Updates(map[string]interface{}{
-                "status":   models.StatusDone,
-                "progress": 100,
-            })
-        }
-        time.Sleep(2 * time.Second) // Poll every 2 seconds
-    }
-}(db)
+	// Start background worker to process queued crawls automatically (WORKER_CONCURRENCY crawls at a time)
+	// Crawls left running by a restart go back to the queue and resume from their frontier
+	if err := worker.RequeueInterrupted(db); err != nil {
+		logWithLevel("WARN", "Failed to requeue interrupted crawls: %v", err)
+	}
+	concurrency, _ := strconv.Atoi(os.Getenv("WORKER_CONCURRENCY"))
+	worker.New(db, worker.NewScheduler(db, quotas), siteCrawler, concurrency).Start(context.Background())
 
 	// Start server
 	port := os.Getenv("PORT")